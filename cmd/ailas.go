@@ -3,7 +3,6 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/spf13/cobra"
@@ -42,8 +41,10 @@ func createAlias(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := a.createAlias(args[0], args[1], req)
-				fmt.Fprintln(out, res)
-				return err
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -71,8 +72,10 @@ func getAlias(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := a.getAlias(args[0], isAlias)
-				fmt.Fprintln(out, res)
-				return err
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, aliasLayout)
 			},
 		}
 	)
@@ -98,8 +101,10 @@ func deleteAlias(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := a.deleteAlias(args[0], args[1])
-				fmt.Fprintln(out, res)
-				return err
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
 			},
 		}
 	)
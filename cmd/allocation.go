@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"strings"
+)
+
+const (
+	unassignedState = "UNASSIGNED"
+	decisionYes     = "YES"
+)
+
+var allocationExplanationColumns = []string{"index", "shard", "prirep", "reason", "node", "decision", "deciders", "explanation"}
+
+// allocationExplanation explains the unassigned shards, one row per group of shards and node.
+type allocationExplanation struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+type shardRow struct {
+	Index  string `json:"index"`
+	Shard  string `json:"shard"`
+	Prirep string `json:"prirep"`
+	State  string `json:"state"`
+	Reason string `json:"unassigned.reason"`
+}
+
+type shardAllocationDecision struct {
+	UnassignedInfo struct {
+		Reason string `json:"reason"`
+	} `json:"unassigned_info"`
+	AllocateExplanation string `json:"allocate_explanation"`
+	NodeDecisions       []struct {
+		NodeName     string `json:"node_name"`
+		NodeDecision string `json:"node_decision"`
+		Deciders     []struct {
+			Decider     string `json:"decider"`
+			Decision    string `json:"decision"`
+			Explanation string `json:"explanation"`
+		} `json:"deciders"`
+	} `json:"node_allocation_decisions"`
+}
+
+type allocationExplanationRow struct {
+	Index       string `json:"index"`
+	Shard       string `json:"shard"`
+	Prirep      string `json:"prirep"`
+	Reason      string `json:"reason"`
+	Node        string `json:"node"`
+	Decision    string `json:"decision"`
+	Deciders    string `json:"deciders"`
+	Explanation string `json:"explanation"`
+}
+
+func (o *allocationExplanation) cat() (res *esapi.Response, err error) {
+	if o.Help {
+		return catHelp(allocationExplanationColumns), nil
+	}
+	res, err = o.Client.Cat.Shards(
+		o.Client.Cat.Shards.WithFormat("json"),
+		o.Client.Cat.Shards.WithH("index", "shard", "prirep", "state", "unassigned.reason"),
+	)
+	if err != nil || res.IsError() {
+		return res, err
+	}
+	defer res.Body.Close()
+	var shards []shardRow
+	if err = json.NewDecoder(res.Body).Decode(&shards); err != nil {
+		return nil, err
+	}
+	rows := make([]allocationExplanationRow, 0)
+	e := Explain{Client: o.Client}
+	for _, s := range groupUnassigned(shards) {
+		rows = append(rows, o.explain(&e, s)...)
+	}
+	return o.response(rows)
+}
+
+// groupUnassigned groups the unassigned shards by index, primary and reason,
+// which share their explanation, so that thousands of unassigned shards take
+// one explain per index rather than one each. The shard of a group lists all
+// of its shard numbers.
+func groupUnassigned(shards []shardRow) []shardRow {
+	var (
+		groups  []shardRow
+		grouped = make(map[shardRow]int)
+		seen    = make(map[shardRow]bool)
+	)
+	for _, s := range shards {
+		if s.State != unassignedState {
+			continue
+		}
+		key := shardRow{Index: s.Index, Prirep: s.Prirep, Reason: s.Reason}
+		k, ok := grouped[key]
+		if !ok {
+			grouped[key] = len(groups)
+			groups = append(groups, s)
+			seen[s] = true
+			continue
+		}
+		// the replicas of a shard share its number
+		if !seen[s] {
+			seen[s] = true
+			groups[k].Shard += "," + s.Shard
+		}
+	}
+	return groups
+}
+
+// explain turns the allocation explanation of an unassigned shard into rows,
+// a shard that can not be explained, like one assigned meanwhile, keeps the error.
+func (o *allocationExplanation) explain(e *Explain, s shardRow) []allocationExplanationRow {
+	row := allocationExplanationRow{Index: s.Index, Shard: s.Shard, Prirep: s.Prirep, Reason: s.Reason}
+	// a group is explained by its first shard
+	shard := strings.SplitN(s.Shard, ",", 2)[0]
+	res, err := e.explainShard(&ExplainBody{Index: s.Index, Shard: shard, Primary: s.Prirep == "p"})
+	if err == nil {
+		err = es.CheckResponse(res)
+	}
+	if err != nil {
+		row.Explanation = err.Error()
+		return []allocationExplanationRow{row}
+	}
+	defer res.Body.Close()
+	var decision shardAllocationDecision
+	if err := json.NewDecoder(res.Body).Decode(&decision); err != nil {
+		row.Explanation = err.Error()
+		return []allocationExplanationRow{row}
+	}
+	return decision.rows(row)
+}
+
+func (d *shardAllocationDecision) rows(shard allocationExplanationRow) []allocationExplanationRow {
+	if shard.Reason == "" {
+		shard.Reason = d.UnassignedInfo.Reason
+	}
+	if len(d.NodeDecisions) == 0 {
+		shard.Explanation = d.AllocateExplanation
+		return []allocationExplanationRow{shard}
+	}
+	var rows []allocationExplanationRow
+	for _, n := range d.NodeDecisions {
+		row := shard
+		row.Node = n.NodeName
+		row.Decision = n.NodeDecision
+		var deciders []string
+		for _, dc := range n.Deciders {
+			if dc.Decision == decisionYes {
+				continue
+			}
+			deciders = append(deciders, dc.Decider)
+			if row.Explanation == "" {
+				row.Explanation = dc.Explanation
+			}
+		}
+		row.Deciders = strings.Join(deciders, ",")
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// response renders rows the way the cat api would, json when asked for.
+// The rows are built here, so columns and sort are applied here too.
+func (o *allocationExplanation) response(rows []allocationExplanationRow) (*esapi.Response, error) {
+	r := &catRows{columns: allocationExplanationColumns}
+	for _, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		r.rows = append(r.rows, m)
+	}
+	r.sort(o.Sort)
+	r.project(o.Columns)
+	return r.response(o.Format)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+	"testing"
+)
+
+const (
+	unassignedShards = `[
+{"index":"noah","shard":"0","prirep":"p","state":"STARTED","unassigned.reason":null},
+{"index":"noah","shard":"0","prirep":"r","state":"UNASSIGNED","unassigned.reason":"NODE_LEFT"}]`
+	replicaExplanation = `{"index":"noah","shard":0,"primary":false,"current_state":"unassigned",
+"unassigned_info":{"reason":"NODE_LEFT"},"can_allocate":"no",
+"allocate_explanation":"cannot allocate because allocation is not permitted to any of the nodes",
+"node_allocation_decisions":[
+{"node_name":"node-1","node_decision":"no","deciders":[
+{"decider":"same_shard","decision":"NO","explanation":"a copy of this shard is already allocated to this node"},
+{"decider":"disk_threshold","decision":"NO","explanation":"the node is above the low watermark"}]},
+{"node_name":"node-2","node_decision":"no","deciders":[
+{"decider":"awareness","decision":"NO","explanation":"too many copies of the shard allocated to nodes with attribute [zone]"}]}]}`
+)
+
+func TestCatAllocationExplanation(t *testing.T) {
+	mock := fake.MockEsRoutes{
+		"/_cat/shards":                 unassignedShards,
+		"/_cluster/allocation/explain": replicaExplanation,
+	}
+	out, err := executeCommand("cat allocationExp -o json", mock)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+{"index":"noah","shard":"0","prirep":"r","reason":"NODE_LEFT","node":"node-1","decision":"no","deciders":"same_shard,disk_threshold","explanation":"a copy of this shard is already allocated to this node"},
+{"index":"noah","shard":"0","prirep":"r","reason":"NODE_LEFT","node":"node-2","decision":"no","deciders":"awareness","explanation":"too many copies of the shard allocated to nodes with attribute [zone]"}]`, out)
+
+	out, err = executeCommand("cat allocationExp -o name", mock)
+	require.NoError(t, err)
+	require.Equal(t, "noah\nnoah\n", out)
+
+	out, err = executeCommand("cat allocationExp", mock)
+	require.NoError(t, err)
+	require.Contains(t, out, "index shard prirep reason    node   decision deciders                  explanation\n")
+	require.Contains(t, out, "noah  0     r      NODE_LEFT node-1 no       same_shard,disk_threshold a copy of this shard is already allocated to this node\n")
+}
+
+func TestCatAllocationExplanationWithoutNodes(t *testing.T) {
+	mock := fake.MockEsRoutes{
+		"/_cat/shards":                 unassignedShards,
+		"/_cluster/allocation/explain": `{"unassigned_info":{"reason":"INDEX_CREATED"},"allocate_explanation":"no nodes"}`,
+	}
+	out, err := executeCommand("cat allocationExp -o json", mock)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"index":"noah","shard":"0","prirep":"r","reason":"NODE_LEFT","node":"","decision":"","deciders":"","explanation":"no nodes"}]`, out)
+
+	out, err = executeCommand("cat allocationExp -o json", fake.MockEsRoutes{"/_cat/shards": `[]`})
+	require.NoError(t, err)
+	require.JSONEq(t, `[]`, out)
+}
+
+func TestCatAllocationExplanationGroups(t *testing.T) {
+	mock := &fake.MockEsRecorder{
+		Routes: fake.MockEsRoutes{
+			"/_cat/shards": `[
+{"index":"noah","shard":"0","prirep":"r","state":"UNASSIGNED","unassigned.reason":"NODE_LEFT"},
+{"index":"noah","shard":"0","prirep":"r","state":"UNASSIGNED","unassigned.reason":"NODE_LEFT"},
+{"index":"noah","shard":"1","prirep":"r","state":"UNASSIGNED","unassigned.reason":"NODE_LEFT"},
+{"index":"noah","shard":"2","prirep":"p","state":"UNASSIGNED","unassigned.reason":"NODE_LEFT"}]`,
+			"/_cluster/allocation/explain": `{"allocate_explanation":"no nodes"}`,
+		},
+	}
+	out, err := executeCommand("cat allocationExp -o json", mock)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+{"index":"noah","shard":"0,1","prirep":"r","reason":"NODE_LEFT","node":"","decision":"","deciders":"","explanation":"no nodes"},
+{"index":"noah","shard":"2","prirep":"p","reason":"NODE_LEFT","node":"","decision":"","deciders":"","explanation":"no nodes"}]`, out)
+	explains := 0
+	for _, r := range mock.Requests() {
+		if r.Path == "/_cluster/allocation/explain" {
+			explains++
+		}
+	}
+	require.Equal(t, 2, explains)
+}
+
+func TestCatUnknownResource(t *testing.T) {
+	_, err := executeCommand("cat nosuch", &fake.MockEsResponse{})
+	require.EqualError(t, err, "no such resources [nosuch]")
+}
@@ -2,8 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"encoding/json"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/pkg/errors"
@@ -13,20 +11,23 @@ import (
 	"log"
 )
 
-var (
-	clusterConcurrentRebalanced    string
-	nodeConcurrentRecoveries       string
-	nodeInitialPrimariesRecoveries string
-	breakerFielddata               string
-	breakerRequest                 string
-	breakerTotal                   string
-	watermarkHigh                  string
-	watermarkLow                   string
-	maxCompilationsRate            string
-	maxShardsPerNode               string
-	allocationEnable               string
-	maxBytesPerSec                 string
-)
+// applySettingsFlags maps the flags of apply settings to the dotted keys of the cluster settings.
+var applySettingsFlags = []struct {
+	flag, key, usage string
+}{
+	{"cluster_concurrent_rebalanced", "cluster.routing.allocation.cluster_concurrent_rebalance", "to set cluster_concurrent_rebalanced value, such as 10 (Only for 'settings' resource)"},
+	{"node_concurrent_recoveries", "cluster.routing.allocation.node_concurrent_recoveries", "to set node_concurrent_recoveries value, such as 10 (Only for 'settings' resource)"},
+	{"node_initial_primaries_recoveries", "cluster.routing.allocation.node_initial_primaries_recoveries", "to set node_initial_primaries_recoveries value, such as 10 (Only for 'settings' resource)"},
+	{"breaker_fielddata", "indices.breaker.fielddata.limit", "to set breaker_fielddata value, such as 60% (Only for 'settings' resource)"},
+	{"breaker_request", "indices.breaker.request.limit", "to set breaker_request value, such as 60% (Only for 'settings' resource)"},
+	{"breaker_total", "indices.breaker.total.limit", "to set breaker_total value, such as 60% (Only for 'settings' resource)"},
+	{"watermark_high", "cluster.routing.allocation.disk.watermark.high", "to set watermark_high value, such as 85% (Only for 'settings' resource)"},
+	{"watermark_low", "cluster.routing.allocation.disk.watermark.low", "to set watermark_low value, such as 85% (Only for 'settings' resource)"},
+	{"max_compilations_rate", "script.max_compilations_rate", "to set max_compilations_rate value, such as 75/5m (Only for 'settings' resource)"},
+	{"max_shards_per_node", "cluster.max_shards_per_node", "to set max_shards_per_node value, such as 1000 (Only for 'settings' resource)"},
+	{"allocation_enable", "cluster.routing.allocation.enable", "to set allocation enable value, primaries or null (Only for 'settings' resource)"},
+	{"max_bytes_per_sec", "indices.recovery.max_bytes_per_sec", "to set indices recovery max_bytes_per_sec, default 40 (Only for 'settings' resource)"},
+}
 
 func apply(cli *elasticsearch.Client, out io.Writer, osArgs []string) *cobra.Command {
 	var command = &cobra.Command{
@@ -43,6 +44,7 @@ func apply(cli *elasticsearch.Client, out io.Writer, osArgs []string) *cobra.Com
 func applySettings(cli *elasticsearch.Client, out io.Writer, osArgs []string) *cobra.Command {
 	var (
 		req     = &es.RequestBody{}
+		values  = make(map[string]*string)
 		command = &cobra.Command{
 			Use:               "settings --[flag] ",
 			Short:             "apply cluster settings change",
@@ -54,28 +56,18 @@ func applySettings(cli *elasticsearch.Client, out io.Writer, osArgs []string) *c
 					return errors.New("At lease one flag should be specified to change cluster settings")
 				}
 				o := applyObject{Client: cli}
-				res, err := o.putSettings(req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				res, err := o.putSettings(req, values)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
 	f := command.Flags()
-	f.StringVar(&clusterConcurrentRebalanced, "cluster_concurrent_rebalanced", "", "to set cluster_concurrent_rebalanced value, such as 10 (Only for 'settings' resource)")
-	f.StringVar(&nodeConcurrentRecoveries, "node_concurrent_recoveries", "", "to set node_concurrent_recoveries value, such as 10 (Only for 'settings' resource)")
-	f.StringVar(&nodeInitialPrimariesRecoveries, "node_initial_primaries_recoveries", "", "to set node_initial_primaries_recoveries value, such as 10 (Only for 'settings' resource)")
-	f.StringVar(&breakerFielddata, "breaker_fielddata", "", "to set breaker_fielddata value, such as 60% (Only for 'settings' resource)")
-	f.StringVar(&breakerRequest, "breaker_request", "", "to set breaker_request value, such as 60% (Only for 'settings' resource)")
-	f.StringVar(&breakerTotal, "breaker_total", "", "to set breaker_total value, such as 60% (Only for 'settings' resource)")
-	f.StringVar(&watermarkHigh, "watermark_high", "", "to set watermark_high value, such as 85% (Only for 'settings' resource)")
-	f.StringVar(&watermarkLow, "watermark_low", "", "to set watermark_low value, such as 85% (Only for 'settings' resource)")
-	f.StringVar(&maxCompilationsRate, "max_compilations_rate", "", "to set max_compilations_rate value, such as 75/5m (Only for 'settings' resource)")
-	f.StringVar(&maxShardsPerNode, "max_shards_per_node", "", "to set max_shards_per_node value, such as 1000 (Only for 'settings' resource)")
-	f.StringVar(&allocationEnable, "allocation_enable", "", "to set allocation enable value, primaries or null (Only for 'settings' resource)")
-	f.StringVar(&maxBytesPerSec, "max_bytes_per_sec", "", "to set indices recovery max_bytes_per_sec, default 40 (Only for 'settings' resource)")
-
+	for _, s := range applySettingsFlags {
+		values[s.key] = f.String(s.flag, "", s.usage)
+	}
 	err := command.RegisterFlagCompletionFunc("allocation_enable", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"primaries", "null"}, cobra.ShellCompDirectiveNoFileComp
 	})
@@ -98,10 +90,10 @@ func applyFlushed(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			RunE: func(cmd *cobra.Command, args []string) error {
 				o := applyObject{Client: cli}
 				res, err := o.flush()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -119,10 +111,10 @@ func applyClearCache(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			RunE: func(cmd *cobra.Command, args []string) error {
 				o := applyObject{Client: cli}
 				res, err := o.clearCache()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -133,28 +125,21 @@ type applyObject struct {
 	Client *elasticsearch.Client
 }
 
-func (o *applyObject) putSettings(req *es.RequestBody) (*esapi.Response, error) {
+func (o *applyObject) putSettings(req *es.RequestBody, values map[string]*string) (*esapi.Response, error) {
 	data, err := es.GetRawRequestBody(req)
 	if err != nil {
 		return nil, err
 	}
 	if data == nil {
-		settings := NewSettings()
-		settings.WithAllocationSettings(
-			clusterConcurrentRebalanced,
-			nodeConcurrentRecoveries,
-			nodeInitialPrimariesRecoveries,
-			allocationEnable).
-			WithBreakerRequest(breakerRequest).
-			WithBreakerTotal(breakerTotal).
-			WithBreakerRequest(breakerRequest).
-			WithWatermark(watermarkHigh, watermarkLow).
-			WithRecovery(maxBytesPerSec).
-			WithMaxShardsPerNode(maxShardsPerNode).
-			WithMaxCompilationsRate(maxCompilationsRate)
-		data, err = json.Marshal(settings)
+		settings := make(map[string]interface{})
+		for key, v := range values {
+			if *v != "" {
+				settings[key] = settingValue(*v)
+			}
+		}
+		data, err = settingsBody(settings, false)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to Marshal settings")
+			return nil, err
 		}
 	}
 	putSettings, err := o.Client.Cluster.PutSettings(bytes.NewReader(data), o.Client.Cluster.PutSettings.WithPretty())
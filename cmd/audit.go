@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/toughnoah/blackbean/pkg/audit"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/printer"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var auditLayout = &printer.Resource{
+	Columns: []printer.Column{
+		{Header: "TIME", Path: "time"},
+		{Header: "USER", Path: "user"},
+		{Header: "CLUSTER", Path: "cluster"},
+		{Header: "METHOD", Path: "method"},
+		{Header: "PATH", Path: "path"},
+		{Header: "STATUS", Path: "status"},
+	},
+	WideColumns: []printer.Column{
+		{Header: "COMMAND", Path: "command"},
+		{Header: "BODY_SHA256", Path: "body_sha256"},
+		{Header: "ERROR", Path: "error"},
+	},
+}
+
+func auditCmd(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:               "audit [subcommand]",
+		Short:             "read the local audit log of requests changing clusters",
+		Long:              "read the local audit log of requests changing clusters ... wordless",
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: noCompletions,
+	}
+	command.AddCommand(listAudit(out))
+	return command
+}
+
+func listAudit(out io.Writer) *cobra.Command {
+	var (
+		since   time.Duration
+		command = &cobra.Command{
+			Use:               "list",
+			Short:             "list audited requests, of the cluster given by --cluster if any",
+			Long:              "list audited requests ... wordless",
+			Args:              cobra.NoArgs,
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				l, err := auditLog()
+				if err != nil {
+					return err
+				}
+				filter := audit.Filter{Cluster: clusterName}
+				if since > 0 {
+					filter.Since = time.Now().Add(-since)
+				}
+				records, err := l.Read(filter)
+				if err != nil {
+					return err
+				}
+				if records == nil {
+					records = []audit.Record{}
+				}
+				body, err := json.Marshal(records)
+				if err != nil {
+					return err
+				}
+				format := output
+				if format == "" {
+					format = printer.Table
+				}
+				p, err := printer.New(format, auditLayout)
+				if err != nil {
+					return err
+				}
+				return p.Print(out, body)
+			},
+		}
+	)
+	command.Flags().DurationVar(&since, "since", 0, "only list requests of this long ago, like 24h.")
+	return command
+}
+
+// auditLog is the log set by 'audit_log' in .blackbean, audit.DefaultPath if unset.
+func auditLog() (*audit.Log, error) {
+	path := viper.GetString(es.AuditLogSpec)
+	if path == "" {
+		path = audit.DefaultPath
+	}
+	l, err := audit.NewLog(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit log")
+	}
+	return l, nil
+}
+
+// auditing records the requests changing the current cluster, made by the command line args.
+func auditing(args []string) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		l, err := auditLog()
+		if err != nil {
+			log.Printf("audit log disabled: %s", err)
+			return next
+		}
+		cluster, _ := es.CurrentCluster()
+		return audit.NewTransport(next, l, audit.Record{
+			User:    audit.CurrentUser(),
+			Cluster: cluster,
+			Command: auditCommandLine(args),
+		})
+	}
+}
+
+// maskedFlags are the flags whose values may hold passwords or documents.
+var maskedFlags = []string{"-d", "--data", "-H", "--header"}
+
+// auditCommandLine is the command line with the values of maskedFlags replaced by
+// their sha256, so that the log tells commands apart without keeping their bodies.
+func auditCommandLine(args []string) string {
+	masked := make([]string, 0, len(args))
+	for k := 0; k < len(args); k++ {
+		if es.Check(args[k], maskedFlags) && k+1 < len(args) {
+			masked = append(masked, args[k], maskValue(args[k+1]))
+			k++
+			continue
+		}
+		masked = append(masked, maskGluedValue(args[k]))
+	}
+	return strings.TrimSpace("blackbean " + strings.Join(masked, " "))
+}
+
+// maskGluedValue masks the value of a flag given in the same arg, like --data={} or -d{}.
+func maskGluedValue(arg string) string {
+	for _, flag := range maskedFlags {
+		if strings.HasPrefix(arg, flag+"=") {
+			return flag + "=" + maskValue(strings.TrimPrefix(arg, flag+"="))
+		}
+		if len(flag) == 2 && len(arg) > 2 && strings.HasPrefix(arg, flag) {
+			return flag + maskValue(strings.TrimPrefix(arg, flag))
+		}
+	}
+	return arg
+}
+
+func maskValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "<sha256:" + hex.EncodeToString(sum[:]) + ">"
+}
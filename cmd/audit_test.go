@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/audit"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+func TestAuditList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	previous := viper.GetString(es.AuditLogSpec)
+	defer viper.Set(es.AuditLogSpec, previous)
+	viper.Set(es.AuditLogSpec, filepath.Join(dir, "audit.log"))
+	defer func() { clusterName = "" }()
+
+	l, err := auditLog()
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, r := range []audit.Record{
+		{Time: now.Add(-48 * time.Hour), User: "noah", Cluster: "prod", Method: "PUT", Path: "/_cluster/settings", Status: 200},
+		{Time: now.Add(-time.Hour), User: "noah", Cluster: "qa", Method: "DELETE", Path: "/qa-index", Status: 200},
+		{Time: now.Add(-time.Hour), User: "noah", Cluster: "prod", Method: "DELETE", Path: "/prod-index", Status: 404},
+	} {
+		require.NoError(t, l.Write(r))
+	}
+
+	o, err := executeCommand("audit list --since 24h --cluster prod", nil)
+	require.NoError(t, err)
+	require.Contains(t, o, "TIME")
+	require.Contains(t, o, "/prod-index")
+	require.NotContains(t, o, "/qa-index")
+	require.NotContains(t, o, "/_cluster/settings")
+
+	clusterName = ""
+	o, err = executeCommand("audit list", nil)
+	require.NoError(t, err)
+	require.Contains(t, o, "/qa-index")
+	require.Contains(t, o, "/_cluster/settings")
+}
+
+func TestAuditCommandLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	previous := viper.GetString(es.AuditLogSpec)
+	defer viper.Set(es.AuditLogSpec, previous)
+	viper.Set(es.AuditLogSpec, filepath.Join(dir, "audit.log"))
+
+	mock := &fake.MockEsResponse{ResponseString: `{"acknowledged":true}`}
+	_, err = executeCommand("index delete noah --yes", mock)
+	require.NoError(t, err)
+
+	l, err := auditLog()
+	require.NoError(t, err)
+	records, err := l.Read(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "DELETE", records[0].Method)
+	require.Equal(t, "blackbean index delete noah --yes", records[0].Command)
+}
+
+func TestAuditCommandLineMasksBodies(t *testing.T) {
+	password := `{"password":"bulldog123","roles":["superuser"]}`
+	sum := sha256.Sum256([]byte(password))
+	masked := "<sha256:" + hex.EncodeToString(sum[:]) + ">"
+	testCases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"user", "create", "bob", "-d", password}, "blackbean user create bob -d " + masked},
+		{[]string{"user", "create", "bob", "--data", password}, "blackbean user create bob --data " + masked},
+		{[]string{"user", "create", "bob", "--data=" + password}, "blackbean user create bob --data=" + masked},
+		{[]string{"user", "create", "bob", "-d" + password}, "blackbean user create bob -d" + masked},
+		{[]string{"index", "delete", "noah", "--yes"}, "blackbean index delete noah --yes"},
+	}
+	for _, tc := range testCases {
+		got := auditCommandLine(tc.args)
+		require.Equal(t, tc.want, got)
+		require.NotContains(t, got, "bulldog123")
+	}
+	require.Contains(t, auditCommandLine([]string{"raw", "GET", "/", "-H", "Authorization: Basic c2VjcmV0"}), "-H <sha256:")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/printer"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type bulkResponse struct {
+	Took   int64                       `json:"took"`
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+// bulkItemResult is the outcome of one action of a bulk request.
+type bulkItemResult struct {
+	Action string         `json:"-"`
+	Index  string         `json:"_index"`
+	ID     string         `json:"_id"`
+	Status int            `json:"status"`
+	Error  *es.ErrorCause `json:"error,omitempty"`
+}
+
+// results flattens the items, each an object keyed by its action, in request order.
+func (b *bulkResponse) results() []bulkItemResult {
+	results := make([]bulkItemResult, 0, len(b.Items))
+	for _, item := range b.Items {
+		for action, r := range item {
+			r.Action = action
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// failures are the failed items, grouped by error type, the most frequent first.
+func (b *bulkResponse) failures() []bulkItemResult {
+	var failed []bulkItemResult
+	count := map[string]int{}
+	for _, r := range b.results() {
+		if r.Error != nil {
+			failed = append(failed, r)
+			count[r.Error.Type]++
+		}
+	}
+	sort.SliceStable(failed, func(i, j int) bool {
+		ti, tj := failed[i].Error.Type, failed[j].Error.Type
+		if count[ti] != count[tj] {
+			return count[ti] > count[tj]
+		}
+		return ti < tj
+	})
+	return failed
+}
+
+// printBulkResponse reports the failed items of a bulk response instead of the
+// whole response, or prints it as asked by -o/--output. Failed items make an
+// error unless failOnError is false.
+func printBulkResponse(out io.Writer, res *esapi.Response, failOnError bool) error {
+	if err := es.CheckResponse(res); err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if isDryRun(res.Header) {
+		return nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var parsed bulkResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return errors.Wrap(err, "failed to parse bulk response")
+	}
+	failed := parsed.failures()
+	if output != "" {
+		p, err := printer.New(output, nil)
+		if err != nil {
+			return err
+		}
+		if err := p.Print(out, body); err != nil {
+			return err
+		}
+	} else if len(failed) == 0 {
+		fmt.Fprintf(out, "%d items in %dms, no errors\n", len(parsed.Items), parsed.Took)
+	} else {
+		printBulkFailures(out, failed, len(parsed.Items))
+	}
+	if len(failed) == 0 || !failOnError {
+		return nil
+	}
+	return errors.Errorf("%d of %d bulk items failed", len(failed), len(parsed.Items))
+}
+
+func printBulkFailures(out io.Writer, failed []bulkItemResult, total int) {
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tINDEX\tID\tSTATUS\tREASON")
+	var groups []string
+	for k, r := range failed {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", r.Error.Type, r.Index, r.ID, r.Status, r.Error.Reason)
+		if k == 0 || failed[k-1].Error.Type != r.Error.Type {
+			groups = append(groups, r.Error.Type)
+		}
+	}
+	_ = w.Flush()
+	var counts []string
+	for _, g := range groups {
+		n := 0
+		for _, r := range failed {
+			if r.Error.Type == g {
+				n++
+			}
+		}
+		counts = append(counts, fmt.Sprintf("%d %s", n, g))
+	}
+	fmt.Fprintf(out, "%d of %d items failed: %s\n", len(failed), total, strings.Join(counts, ", "))
+}
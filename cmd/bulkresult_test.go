@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+const failedBulk = `{"took":30,"errors":true,"items":[
+{"index":{"_index":"noah","_id":"1","status":201}},
+{"index":{"_index":"noah","_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [age]"}}},
+{"create":{"_index":"noah","_id":"3","status":409,"error":{"type":"version_conflict_engine_exception","reason":"[3]: version conflict"}}},
+{"delete":{"_index":"noah","_id":"4","status":200}},
+{"index":{"_index":"noah","_id":"5","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [name]"}}}]}`
+
+func TestBulkFailures(t *testing.T) {
+	var res bulkResponse
+	require.NoError(t, json.Unmarshal([]byte(failedBulk), &res))
+	require.Len(t, res.results(), 5)
+	require.Equal(t, "create", res.results()[2].Action)
+	var ids []string
+	for _, r := range res.failures() {
+		ids = append(ids, r.ID)
+	}
+	require.Equal(t, []string{"2", "5", "3"}, ids)
+
+	out := new(bytes.Buffer)
+	printBulkFailures(out, res.failures(), len(res.Items))
+	require.Equal(t, `TYPE                                INDEX   ID   STATUS   REASON
+mapper_parsing_exception            noah    2    400      failed to parse field [age]
+mapper_parsing_exception            noah    5    400      failed to parse field [name]
+version_conflict_engine_exception   noah    3    409      [3]: version conflict
+3 of 5 items failed: 2 mapper_parsing_exception, 1 version_conflict_engine_exception
+`, out.String())
+}
+
+func TestBulkCommandFailures(t *testing.T) {
+	mock := &fake.MockEsResponse{ResponseString: failedBulk}
+	o, err := executeCommand(`index bulk noah -d '{"index":{}}'`, mock)
+	require.EqualError(t, err, "3 of 5 bulk items failed")
+	require.Contains(t, o, "3 of 5 items failed: 2 mapper_parsing_exception, 1 version_conflict_engine_exception")
+	require.NotContains(t, o, `"took"`)
+
+	o, err = executeCommand(`index bulk noah -d '{"index":{}}' --fail_on_error=false`, mock)
+	require.NoError(t, err)
+	require.Contains(t, o, "version_conflict_engine_exception   noah    3    409")
+
+	mock = &fake.MockEsResponse{ResponseString: `{"took":3,"errors":false,"items":[{"index":{"_index":"noah","_id":"1","status":201}}]}`}
+	o, err = executeCommand(`index bulk noah -d '{"index":{}}'`, mock)
+	require.NoError(t, err)
+	require.Contains(t, o, "1 items in 3ms, no errors\n")
+}
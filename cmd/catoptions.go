@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/printer"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	byteUnits = []string{"b", "kb", "mb", "gb", "tb", "pb"}
+	// timeUnits are the suffixes of cat times in seconds, longest first so ms is not read as m
+	timeUnits = []struct {
+		suffix string
+		scale  float64
+	}{{"nanos", 1e-9}, {"micros", 1e-6}, {"ms", 1e-3}, {"s", 1}, {"m", 60}, {"h", 3600}, {"d", 86400}}
+	// whereExpr is a client side row filter like health!=green or docs.count>=100
+	whereExpr = regexp.MustCompile(`^([^!=<>]+)(!=|==|>=|<=|=|>|<)(.*)$`)
+)
+
+// catOptions are the query parameters shared by every cat resource.
+type catOptions struct {
+	Format  string
+	Help    bool
+	Columns []string
+	Sort    []string
+	Bytes   string
+	// Limit and Where are applied by blackbean to the returned rows.
+	Limit int
+	Where []string
+	// Health and Status filter indices only.
+	Health string
+	Status string
+}
+
+func (o *catOptions) options() *catOptions {
+	return o
+}
+
+// columns are the columns asked for by --columns, or the defaults of the resource.
+func (o *catOptions) columns(defaults ...string) []string {
+	if len(o.Columns) != 0 {
+		return o.Columns
+	}
+	return defaults
+}
+
+func (o *catOptions) sort(defaults ...string) []string {
+	if len(o.Sort) != 0 {
+		return o.Sort
+	}
+	return defaults
+}
+
+func (o *catOptions) bytes(def string) string {
+	if o.Bytes != "" {
+		return o.Bytes
+	}
+	return def
+}
+
+func (o *catOptions) filtered() bool {
+	return !o.Help && (o.Limit > 0 || len(o.Where) != 0)
+}
+
+func addCatFlags(command *cobra.Command, cli *elasticsearch.Client, opts *catOptions) {
+	f := command.Flags()
+	f.StringSliceVar(&opts.Columns, "columns", nil, "comma separated columns to show, see the ?help of the resource.")
+	f.StringSliceVar(&opts.Sort, "sort", nil, "comma separated columns to sort by, append ':desc' to reverse.")
+	f.StringVar(&opts.Bytes, "bytes", "", "unit of byte values, one of b|kb|mb|gb|tb|pb.")
+	f.IntVar(&opts.Limit, "limit", 0, "show at most this many rows.")
+	f.StringArrayVar(&opts.Where, "where", nil, "only show rows matching, like 'health!=green' or 'docs.count>100', repeat to and them.")
+	err := command.RegisterFlagCompletionFunc("columns", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeColumns(catColumns(args[0], cli), toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = command.RegisterFlagCompletionFunc("sort", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeColumns(catColumns(args[0], cli), toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = command.RegisterFlagCompletionFunc("bytes", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return byteUnits, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (o *catOptions) validate() error {
+	if o.Bytes != "" && !es.Check(o.Bytes, byteUnits) {
+		return errors.Errorf("bad bytes %q, want one of %s", o.Bytes, strings.Join(byteUnits, "|"))
+	}
+	if o.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	for _, w := range o.Where {
+		if !whereExpr.MatchString(w) {
+			return errors.Errorf("bad where %q, want <column><op><value> with op one of = != > >= < <=", w)
+		}
+	}
+	return nil
+}
+
+// catColumns lists the columns of a resource from its ?help.
+func catColumns(resource string, cli *elasticsearch.Client) []string {
+	if !es.Check(resource, resources) {
+		return nil
+	}
+	res, err := NewCatStrategy(resource, cli, catOptions{Help: true}).Cat()
+	if err != nil || res.IsError() {
+		return nil
+	}
+	defer res.Body.Close()
+	var columns []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if column := strings.TrimSpace(strings.SplitN(scanner.Text(), "|", 2)[0]); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// completeColumns completes the last of a comma separated list of columns.
+func completeColumns(columns []string, toComplete string) []string {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	chosen := strings.Split(prefix, ",")
+	var res []string
+	for _, c := range columns {
+		if !es.Check(c, chosen) {
+			res = append(res, prefix+c)
+		}
+	}
+	return res
+}
+
+// catHelp renders columns the way the ?help of the cat api does.
+func catHelp(columns []string) *esapi.Response {
+	buf := new(bytes.Buffer)
+	for _, c := range columns {
+		fmt.Fprintf(buf, "%s | | %s\n", c, c)
+	}
+	return catResponse(buf, "text/plain; charset=UTF-8")
+}
+
+type catRows struct {
+	columns []string
+	rows    []map[string]interface{}
+}
+
+func decodeCatRows(body []byte) (*catRows, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, errors.Wrap(err, "cat response is not a json list")
+	}
+	r := &catRows{}
+	for i, raw := range raws {
+		if i == 0 {
+			r.columns = printer.Keys(raw)
+		}
+		row := make(map[string]interface{})
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.UseNumber()
+		if err := d.Decode(&row); err != nil {
+			return nil, err
+		}
+		r.rows = append(r.rows, row)
+	}
+	return r, nil
+}
+
+// filter applies --where and --limit to a json cat response and renders it in format.
+func (o *catOptions) filter(res *esapi.Response, format string) (*esapi.Response, error) {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decodeCatRows(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.where(o.Where); err != nil {
+		return nil, err
+	}
+	r.limit(o.Limit)
+	return r.response(format)
+}
+
+func (r *catRows) where(exprs []string) error {
+	for _, expr := range exprs {
+		m := whereExpr.FindStringSubmatch(expr)
+		if m == nil {
+			return errors.Errorf("bad where %q", expr)
+		}
+		column, op, value := strings.TrimSpace(m[1]), m[2], strings.TrimSpace(m[3])
+		if len(r.rows) != 0 && !es.Check(column, r.columns) {
+			return errors.Errorf("unknown column %q in where %q", column, expr)
+		}
+		kept := r.rows[:0]
+		for _, row := range r.rows {
+			if matchCell(cell(row[column]), op, value) {
+				kept = append(kept, row)
+			}
+		}
+		r.rows = kept
+	}
+	return nil
+}
+
+func (r *catRows) limit(n int) {
+	if n > 0 && len(r.rows) > n {
+		r.rows = r.rows[:n]
+	}
+}
+
+// sort orders rows client side, for resources the cat api can not sort.
+func (r *catRows) sort(by []string) {
+	sort.SliceStable(r.rows, func(i, j int) bool {
+		for _, s := range by {
+			column, desc := s, false
+			if strings.HasSuffix(s, ":desc") {
+				column, desc = strings.TrimSuffix(s, ":desc"), true
+			}
+			column = strings.TrimSuffix(column, ":asc")
+			c := compareCells(cell(r.rows[i][column]), cell(r.rows[j][column]))
+			if c != 0 {
+				return (c < 0) != desc
+			}
+		}
+		return false
+	})
+}
+
+func (r *catRows) project(columns []string) {
+	if len(columns) != 0 {
+		r.columns = columns
+	}
+}
+
+// response renders rows like the cat api, a json list or a text table with a header.
+func (r *catRows) response(format string) (*esapi.Response, error) {
+	buf := new(bytes.Buffer)
+	if format == "json" {
+		buf.WriteByte('[')
+		for i, row := range r.rows {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('{')
+			for j, c := range r.columns {
+				if j > 0 {
+					buf.WriteByte(',')
+				}
+				k, _ := json.Marshal(c)
+				v, err := json.Marshal(row[c])
+				if err != nil {
+					return nil, err
+				}
+				buf.Write(k)
+				buf.WriteByte(':')
+				buf.Write(v)
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteString("]\n")
+		return catResponse(buf, "application/json; charset=UTF-8"), nil
+	}
+	w := tabwriter.NewWriter(buf, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, strings.Join(r.columns, "\t"))
+	for _, row := range r.rows {
+		cells := make([]string, 0, len(r.columns))
+		for _, c := range r.columns {
+			cells = append(cells, cell(row[c]))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return catResponse(buf, "text/plain; charset=UTF-8"), nil
+}
+
+func catResponse(body *bytes.Buffer, contentType string) *esapi.Response {
+	return &esapi.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       ioutil.NopCloser(body),
+	}
+}
+
+func cell(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// compareCells compares by value when both cells are numbers, sizes like 10gb
+// or times like 1.5s, else as strings.
+func compareCells(a, b string) int {
+	x, okA := cellValue(a)
+	y, okB := cellValue(b)
+	if okA && okB {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// cellValue reads a cell as a plain number, a size in bytes or a time in seconds.
+func cellValue(c string) (float64, bool) {
+	if v, err := strconv.ParseFloat(c, 64); err == nil {
+		return v, true
+	}
+	for i := len(byteUnits) - 1; i >= 0; i-- {
+		if v, ok := scaledValue(c, byteUnits[i], math.Pow(1024, float64(i))); ok {
+			return v, true
+		}
+	}
+	for _, u := range timeUnits {
+		if v, ok := scaledValue(c, u.suffix, u.scale); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func scaledValue(c, suffix string, scale float64) (float64, bool) {
+	if !strings.HasSuffix(c, suffix) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(c, suffix), 64)
+	return v * scale, err == nil
+}
+
+func matchCell(c, op, value string) bool {
+	n := compareCells(c, value)
+	switch op {
+	case "=", "==":
+		return n == 0
+	case "!=":
+		return n != 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+const catIndicesBody = `[
+{"health":"green","status":"open","index":"noah-1","docs.count":"120"},
+{"health":"yellow","status":"open","index":"noah-2","docs.count":"80"},
+{"health":"red","status":"open","index":"noah-3","docs.count":null}]`
+
+func TestCatRowsFilter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   catOptions
+		format string
+		want   string
+	}{
+		{
+			name:   "where not equal",
+			opts:   catOptions{Where: []string{"health!=green"}},
+			format: "json",
+			want:   `[{"health":"yellow","status":"open","index":"noah-2","docs.count":"80"},{"health":"red","status":"open","index":"noah-3","docs.count":null}]`,
+		},
+		{
+			name:   "numeric where",
+			opts:   catOptions{Where: []string{"docs.count>=100"}},
+			format: "json",
+			want:   `[{"health":"green","status":"open","index":"noah-1","docs.count":"120"}]`,
+		},
+		{
+			name:   "wheres are anded and limited",
+			opts:   catOptions{Where: []string{"status=open", "index!=noah-1"}, Limit: 1},
+			format: "json",
+			want:   `[{"health":"yellow","status":"open","index":"noah-2","docs.count":"80"}]`,
+		},
+		{
+			name: "text keeps the column order",
+			opts: catOptions{Limit: 2},
+			want: "health status index  docs.count\ngreen  open   noah-1 120\nyellow open   noah-2 80\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.opts.filter(catResponse(bytes.NewBufferString(catIndicesBody), ""), tc.format)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+			if tc.format == "json" {
+				require.JSONEq(t, tc.want, string(body))
+				return
+			}
+			require.Equal(t, tc.want, string(body))
+		})
+	}
+
+	o := catOptions{Where: []string{"nosuch=1"}}
+	_, err := o.filter(catResponse(bytes.NewBufferString(catIndicesBody), ""), "json")
+	require.EqualError(t, err, `unknown column "nosuch" in where "nosuch=1"`)
+}
+
+func TestCatRowsSort(t *testing.T) {
+	r, err := decodeCatRows([]byte(catIndicesBody))
+	require.NoError(t, err)
+	r.sort([]string{"docs.count:desc"})
+	var got []string
+	for _, row := range r.rows {
+		got = append(got, cell(row["index"]))
+	}
+	require.Equal(t, []string{"noah-1", "noah-2", "noah-3"}, got)
+	r.sort([]string{"health"})
+	got = got[:0]
+	for _, row := range r.rows {
+		got = append(got, cell(row["index"]))
+	}
+	require.Equal(t, []string{"noah-1", "noah-3", "noah-2"}, got)
+}
+
+func TestCompareCells(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"10gb", "9gb", 1},
+		{"1gb", "1024mb", 0},
+		{"512kb", "1mb", -1},
+		{"1.5tb", "900gb", 1},
+		{"100b", "1kb", -1},
+		{"900ms", "1.2s", -1},
+		{"2m", "90s", 1},
+		{"1d", "23h", 1},
+		{"120", "80", 1},
+		{"noah-10", "noah-9", -1},
+		{"green", "1gb", 1},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, compareCells(tc.a, tc.b), "%s vs %s", tc.a, tc.b)
+	}
+	require.True(t, matchCell("10gb", ">", "9gb"))
+	require.False(t, matchCell("900mb", ">=", "1gb"))
+}
+
+func TestCatOptionsValidate(t *testing.T) {
+	require.NoError(t, (&catOptions{Bytes: "gb", Where: []string{"health!=green"}}).validate())
+	require.EqualError(t, (&catOptions{Bytes: "gib"}).validate(), `bad bytes "gib", want one of b|kb|mb|gb|tb|pb`)
+	require.EqualError(t, (&catOptions{Limit: -1}).validate(), "limit must not be negative")
+	require.Error(t, (&catOptions{Where: []string{"health"}}).validate())
+}
+
+func TestCatOptionsDefaults(t *testing.T) {
+	o := catOptions{}
+	require.Equal(t, []string{"index"}, o.columns("index"))
+	require.Equal(t, []string{"store.size:desc"}, o.sort("store.size:desc"))
+	require.Equal(t, "gb", o.bytes("gb"))
+	o = catOptions{Columns: []string{"health"}, Sort: []string{"index"}, Bytes: "mb"}
+	require.Equal(t, []string{"health"}, o.columns("index"))
+	require.Equal(t, []string{"index"}, o.sort("store.size:desc"))
+	require.Equal(t, "mb", o.bytes("gb"))
+}
+
+func TestCompleteColumns(t *testing.T) {
+	columns := []string{"health", "status", "index"}
+	require.Equal(t, columns, completeColumns(columns, ""))
+	require.Equal(t, []string{"health,status", "health,index"}, completeColumns(columns, "health,st"))
+}
+
+func TestCatWithWhere(t *testing.T) {
+	mock := &fake.MockEsResponse{ResponseString: catIndicesBody}
+	out, err := executeCommand("cat indices --where 'health!=green' --limit 1", mock)
+	require.NoError(t, err)
+	require.Contains(t, out, "health status index  docs.count\nyellow open   noah-2 80\n")
+	require.NotContains(t, out, "noah-3")
+
+	out, err = executeCommand("cat allocationExp --help", mock)
+	require.NoError(t, err)
+	require.Contains(t, out, "--columns")
+}
@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"gopkg.in/yaml.v2"
+	"io"
+	"sort"
+)
+
+func clusterConfig(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:               "config [subcommand]",
+		Short:             "manage clusters in .blackbean.yaml",
+		Long:              "manage clusters in .blackbean.yaml, unknown keys are kept as they are ... wordless",
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: noCompletions,
+	}
+	command.AddCommand(addCluster(out))
+	command.AddCommand(deleteCluster(out))
+	command.AddCommand(renameCluster(out))
+	command.AddCommand(setClusterConfig(out))
+	command.AddCommand(viewConfig(out))
+	command.AddCommand(validateConfig(out))
+	return command
+}
+
+func addCluster(out io.Writer) *cobra.Command {
+	var (
+		ci      = &es.ClusterInfo{}
+		command = &cobra.Command{
+			Use:               "add-cluster [cluster]",
+			Short:             "add a cluster to config",
+			Long:              "add a cluster to config ... wordless",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := ci.Validate(); err != nil {
+					return errors.Wrapf(err, "invalid cluster %q", args[0])
+				}
+				m := new(Modify)
+				if err := m.AddCluster(args[0], ci); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "cluster %s added\n", args[0])
+				return nil
+			},
+		}
+	)
+	f := command.Flags()
+	f.StringVar(&ci.Url, es.ConfigUrl, "", "url of the cluster")
+	f.StringSliceVar(&ci.Urls, "urls", nil, "urls of several nodes of the cluster, use ',' to split multi urls")
+	f.StringVar(&ci.CloudID, es.ConfigCloudID, "", "elastic cloud id of the cluster")
+	f.StringVar(&ci.Username, es.ConfigUsername, "", "username of basic auth")
+	f.StringVar(&ci.Password, es.ConfigPassword, "", "password of basic auth")
+	f.StringVar(&ci.PasswordEnv, es.ConfigPasswordEnv, "", "env var holding the password of basic auth")
+	f.StringVar(&ci.PasswordFile, "password_file", "", "file holding the password of basic auth")
+	f.StringVar(&ci.PasswordCommand, "password_command", "", "command printing the password of basic auth, such as 'pass show es/prod'")
+	f.StringVar(&ci.APIKey, es.ConfigAPIKey, "", "base64 encoded api key")
+	f.StringVar(&ci.ServiceToken, es.ConfigServiceToken, "", "service token, sent as bearer token")
+	f.StringVar(&ci.CACert, "ca_cert", "", "path to the ca certificate of the cluster")
+	f.BoolVar(&ci.InsecureSkipVerify, "insecure_skip_verify", false, "do not verify the server certificate")
+	f.BoolVar(&ci.Protected, "protected", false, "ask to type the cluster name before destructive commands")
+	f.BoolVar(&ci.ReadOnly, "read_only", false, "refuse to send any request which would change the cluster")
+	return command
+}
+
+func deleteCluster(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "delete-cluster [cluster]",
+		Short: "delete a cluster from config",
+		Long:  "delete a cluster from config ... wordless",
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m := new(Modify)
+			if err := m.DeleteCluster(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "cluster %s deleted\n", args[0])
+			return nil
+		},
+	}
+	return command
+}
+
+func renameCluster(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "rename-cluster [cluster] [newName]",
+		Short: "rename a cluster in config",
+		Long:  "rename a cluster in config, 'current' follows the rename ... wordless",
+		Args:  cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m := new(Modify)
+			if err := m.RenameCluster(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "cluster %s renamed to %s\n", args[0], args[1])
+			return nil
+		},
+	}
+	return command
+}
+
+func setClusterConfig(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "set [cluster] [key] [value]",
+		Short: "set a key of a cluster in config",
+		Long: `set a key of a cluster in config. The value is parsed as yaml, so 'true', '3' or '[502, 503]' keep their type,
+except for secrets like the password which are kept as they are. An empty value removes the key. Run 'config validate' once the cluster is complete.`,
+		Args: cobra.ExactArgs(3),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			switch len(args) {
+			case 0:
+				return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+			case 1:
+				return es.ConfigKeys(), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m := new(Modify)
+			if err := m.SetClusterKey(args[0], args[1], args[2]); err != nil {
+				return err
+			}
+			value := args[2]
+			switch {
+			case value == "":
+				fmt.Fprintf(out, "cluster %s: %s removed\n", args[0], args[1])
+				return nil
+			case es.IsSecretKey(args[1]):
+				value = es.RedactedSecret
+			}
+			fmt.Fprintf(out, "cluster %s: %s set to %s\n", args[0], args[1], value)
+			return nil
+		},
+	}
+	return command
+}
+
+func viewConfig(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "view [cluster]",
+		Short: "view config with secrets masked",
+		Long:  "view config with secrets masked ... wordless",
+		Args:  cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m := new(Modify)
+			view := m.ViewConfig(args)
+			if m.err != nil {
+				return m.err
+			}
+			bytesView, err := yaml.Marshal(view)
+			if err != nil {
+				return err
+			}
+			_, err = out.Write(bytesView)
+			return err
+		},
+	}
+	return command
+}
+
+func validateConfig(out io.Writer) *cobra.Command {
+	var command = &cobra.Command{
+		Use:               "validate",
+		Short:             "validate every cluster in config",
+		Long:              "validate every cluster in config ... wordless",
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m := new(Modify)
+			return m.ValidateConfig(out)
+		},
+	}
+	return command
+}
+
+// clusters returns the 'cluster' map of config, creating it when absent.
+func (m *Modify) clusters(config map[string]interface{}) map[interface{}]interface{} {
+	if m.err != nil {
+		return nil
+	}
+	if config[es.ConfigSpec] == nil {
+		config[es.ConfigSpec] = make(map[interface{}]interface{})
+	}
+	clusters, ok := config[es.ConfigSpec].(map[interface{}]interface{})
+	if !ok {
+		m.err = errors.New("wrong 'cluster' type, want map")
+	}
+	return clusters
+}
+
+func (m *Modify) cluster(clusters map[interface{}]interface{}, name string) map[interface{}]interface{} {
+	if m.err != nil {
+		return nil
+	}
+	raw, ok := clusters[name]
+	if !ok {
+		m.err = es.NoResourcesError(name)
+		return nil
+	}
+	cluster, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		if raw != nil {
+			m.err = errors.Errorf("wrong type of cluster %q, want map", name)
+			return nil
+		}
+		cluster = make(map[interface{}]interface{})
+		clusters[name] = cluster
+	}
+	return cluster
+}
+
+func (m *Modify) AddCluster(name string, ci *es.ClusterInfo) error {
+	path := m.GetConfig()
+	config := m.ReadConfigFile(path)
+	clusters := m.clusters(config)
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := clusters[name]; ok {
+		return errors.Errorf("cluster %q already exists", name)
+	}
+	var raw map[interface{}]interface{}
+	bytesInfo, err := yaml.Marshal(ci)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(bytesInfo, &raw); err != nil {
+		return err
+	}
+	clusters[name] = raw
+	if config[es.CurrentSpec] == nil {
+		config[es.CurrentSpec] = name
+	}
+	m.WriteConfigFile(path, config)
+	return m.err
+}
+
+func (m *Modify) DeleteCluster(name string) error {
+	path := m.GetConfig()
+	config := m.ReadConfigFile(path)
+	clusters := m.clusters(config)
+	m.cluster(clusters, name)
+	if m.err != nil {
+		return m.err
+	}
+	if config[es.CurrentSpec] == name {
+		return errors.Errorf("cluster %q is the current cluster, 'use' another one first", name)
+	}
+	delete(clusters, name)
+	m.WriteConfigFile(path, config)
+	return m.err
+}
+
+func (m *Modify) RenameCluster(name, newName string) error {
+	path := m.GetConfig()
+	config := m.ReadConfigFile(path)
+	clusters := m.clusters(config)
+	cluster := m.cluster(clusters, name)
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := clusters[newName]; ok {
+		return errors.Errorf("cluster %q already exists", newName)
+	}
+	delete(clusters, name)
+	clusters[newName] = cluster
+	if config[es.CurrentSpec] == name {
+		config[es.CurrentSpec] = newName
+	}
+	m.WriteConfigFile(path, config)
+	return m.err
+}
+
+func (m *Modify) SetClusterKey(name, key, value string) error {
+	path := m.GetConfig()
+	config := m.ReadConfigFile(path)
+	cluster := m.cluster(m.clusters(config), name)
+	if m.err != nil {
+		return m.err
+	}
+	switch {
+	case value == "":
+		delete(cluster, key)
+	case es.IsSecretKey(key):
+		// a password like 012345 or yes is no number or bool
+		cluster[key] = value
+	default:
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+			return errors.Wrapf(err, "bad value for %s", key)
+		}
+		cluster[key] = parsed
+	}
+	m.WriteConfigFile(path, config)
+	return m.err
+}
+
+// ViewConfig returns the config, or only the given cluster, with every secret redacted.
+func (m *Modify) ViewConfig(args []string) interface{} {
+	config := m.ReadConfigFile(m.GetConfig())
+	clusters := m.clusters(config)
+	if m.err != nil {
+		return nil
+	}
+	redacted := make(map[interface{}]interface{}, len(clusters))
+	for name, raw := range clusters {
+		cluster, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			redacted[name] = raw
+			continue
+		}
+		masked := make(map[interface{}]interface{}, len(cluster))
+		for k, v := range cluster {
+			if key, ok := k.(string); ok && es.IsSecretKey(key) && v != nil && v != "" {
+				v = es.RedactedSecret
+			}
+			masked[k] = v
+		}
+		redacted[name] = masked
+	}
+	if len(args) != 0 {
+		cluster, ok := redacted[args[0]]
+		if !ok {
+			m.err = es.NoResourcesError(args[0])
+			return nil
+		}
+		return cluster
+	}
+	config[es.ConfigSpec] = redacted
+	return config
+}
+
+func (m *Modify) ValidateConfig(out io.Writer) error {
+	config := m.ReadConfigFile(m.GetConfig())
+	clusters := m.clusters(config)
+	if m.err != nil {
+		return m.err
+	}
+	var (
+		names   []string
+		invalid int
+	)
+	for name := range clusters {
+		names = append(names, fmt.Sprint(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateCluster(clusters[name]); err != nil {
+			invalid++
+			fmt.Fprintf(out, "cluster %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(out, "cluster %s: ok\n", name)
+		}
+	}
+	current, _ := config[es.CurrentSpec].(string)
+	if !es.Check(current, names) {
+		invalid++
+		fmt.Fprintf(out, "current: no cluster named %q\n", current)
+	}
+	if invalid != 0 {
+		return errors.Errorf("%d problem(s) found in config", invalid)
+	}
+	return nil
+}
+
+func validateCluster(raw interface{}) error {
+	ci, err := es.DecodeClusterInfo(raw)
+	if err != nil {
+		return err
+	}
+	if err = ci.Validate(); err != nil {
+		return err
+	}
+	_, err = es.NewTLSConfig(ci)
+	return err
+}
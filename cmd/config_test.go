@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var configExample = []byte(`cluster:
+  default:
+    url: https://a.es.com:9200
+    username: Noah
+    password: abc
+    color: brown
+current: default
+editor: vim
+`)
+
+func TestConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blackbean.yaml")
+	require.NoError(t, ioutil.WriteFile(file, configExample, 0755))
+	readConfig := func() string {
+		raw, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		return string(raw)
+	}
+
+	out, err := executeCommand("config add-cluster qa --url https://b.es.com:9200 --api_key secret --config "+file, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cluster qa added\n", out)
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(ConfigFileMode), info.Mode().Perm())
+	require.Contains(t, readConfig(), "color: brown")
+	require.Contains(t, readConfig(), "editor: vim")
+	require.Contains(t, readConfig(), "api_key: secret")
+
+	_, err = executeCommand("config add-cluster qa --url https://b.es.com:9200 --api_key secret --config "+file, nil)
+	require.EqualError(t, err, `cluster "qa" already exists`)
+	_, err = executeCommand("config add-cluster twoauth --url https://b.es.com:9200 --api_key secret --service_token token --config "+file, nil)
+	require.EqualError(t, err, `invalid cluster "twoauth": at most one auth method is allowed, got api_key, service_token`)
+
+	out, err = executeCommand("config set qa max_retries 5 --config "+file, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cluster qa: max_retries set to 5\n", out)
+	require.Contains(t, readConfig(), "max_retries: 5")
+	out, err = executeCommand("config set qa api_key topsecret --config "+file, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cluster qa: api_key set to ******\n", out)
+	for _, password := range []string{"012345", "yes", "~"} {
+		_, err = executeCommand("config set qa password '"+password+"' --config "+file, nil)
+		require.NoError(t, err)
+		var config struct {
+			Cluster map[string]map[string]interface{} `yaml:"cluster"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(readConfig()), &config))
+		require.Equal(t, password, config.Cluster["qa"]["password"])
+	}
+	_, err = executeCommand("config set qa password '' --config "+file, nil)
+	require.NoError(t, err)
+	_, err = executeCommand("config set nosuch url https://c.es.com --config "+file, nil)
+	require.Error(t, err)
+
+	out, err = executeCommand("config view --config "+file, nil)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(out, "abc"))
+	require.False(t, strings.Contains(out, "topsecret"))
+	require.Contains(t, out, "password: '******'")
+	require.Contains(t, out, "color: brown")
+	out, err = executeCommand("config view qa --config "+file, nil)
+	require.NoError(t, err)
+	require.Contains(t, out, "api_key: '******'")
+	require.False(t, strings.Contains(out, "default"))
+
+	out, err = executeCommand("config validate --config "+file, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cluster default: ok\ncluster qa: ok\n", out)
+
+	_, err = executeCommand("config rename-cluster default prod --config "+file, nil)
+	require.NoError(t, err)
+	require.Contains(t, readConfig(), "current: prod")
+	_, err = executeCommand("config delete-cluster prod --config "+file, nil)
+	require.EqualError(t, err, `cluster "prod" is the current cluster, 'use' another one first`)
+
+	out, err = executeCommand("config set qa api_key '' --config "+file, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cluster qa: api_key removed\n", out)
+	require.False(t, strings.Contains(readConfig(), "api_key"))
+	out, err = executeCommand("config validate --config "+file, nil)
+	require.NoError(t, err, "no auth is fine")
+	require.Equal(t, "cluster prod: ok\ncluster qa: ok\n", out)
+	_, err = executeCommand("config set qa retry_backoff soon --config "+file, nil)
+	require.NoError(t, err)
+	_, err = executeCommand("config validate --config "+file, nil)
+	require.EqualError(t, err, "1 problem(s) found in config")
+
+	_, err = executeCommand("config delete-cluster qa --config "+file, nil)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(readConfig(), "qa:"))
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"strings"
+)
+
+// currentProfile is the profile the client was built from, nil until then.
+var currentProfile *es.Profile
+
+// readConfirmation reads the answer to a confirmation, from the terminal by default.
+var readConfirmation = es.Prompt
+
+func addYesFlag(command *cobra.Command, yes *bool) {
+	command.Flags().BoolVarP(yes, "yes", "y", false, "do not ask to confirm on a protected cluster, for automation.")
+}
+
+// confirmDestructive lists what is about to be destroyed on a protected cluster
+// and asks to type the name of the cluster to go on. targets is only called
+// then, as describing them may take requests.
+func confirmDestructive(out io.Writer, yes bool, action string, targets func() []string) error {
+	if yes || dryRun || currentProfile == nil || currentProfile.ClusterInfo == nil || !currentProfile.ClusterInfo.Protected {
+		return nil
+	}
+	name := currentProfile.Name()
+	fmt.Fprintf(out, "%s on protected cluster %s:\n", action, name)
+	for _, t := range targets() {
+		fmt.Fprintf(out, "  %s\n", t)
+	}
+	answer, err := readConfirmation(fmt.Sprintf("type %q to confirm: ", name))
+	if err != nil {
+		return errors.Wrap(err, "failed to confirm, use --yes to skip the confirmation")
+	}
+	if strings.TrimSpace(answer) != name {
+		return errors.Errorf("%q is not the cluster name, nothing was done", answer)
+	}
+	return nil
+}
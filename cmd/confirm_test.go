@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+func TestConfirmDestructive(t *testing.T) {
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader([]byte(`cluster:
+  prod:
+    url: https://a.es.com
+    api_key: bulldog
+    protected: true
+  qa:
+    url: https://b.es.com
+    api_key: bulldog
+current: qa
+`))))
+	prod, err := es.GetClusterProfile("prod")
+	require.NoError(t, err)
+	qa, err := es.GetClusterProfile("qa")
+	require.NoError(t, err)
+	defer func() {
+		currentProfile = nil
+		readConfirmation = es.Prompt
+	}()
+
+	testCases := []struct {
+		name    string
+		profile *es.Profile
+		yes     bool
+		answer  string
+		readErr error
+		prompt  bool
+		err     string
+	}{
+		{name: "unprotected", profile: qa},
+		{name: "yes", profile: prod, yes: true},
+		{name: "confirmed", profile: prod, answer: "prod", prompt: true},
+		{name: "wrong name", profile: prod, answer: "qa", prompt: true, err: `"qa" is not the cluster name, nothing was done`},
+		{name: "no terminal", profile: prod, readErr: errors.New("no terminal to prompt on"), prompt: true,
+			err: "failed to confirm, use --yes to skip the confirmation: no terminal to prompt on"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			currentProfile = tc.profile
+			prompted := false
+			readConfirmation = func(prompt string) (string, error) {
+				prompted = true
+				require.Equal(t, `type "prod" to confirm: `, prompt)
+				return tc.answer, tc.readErr
+			}
+			out := new(bytes.Buffer)
+			described := false
+			err := confirmDestructive(out, tc.yes, "delete indices", func() []string {
+				described = true
+				return []string{"noah (10 docs, 1kb)"}
+			})
+			require.Equal(t, tc.prompt, described)
+			require.Equal(t, tc.prompt, prompted)
+			if tc.prompt {
+				require.Equal(t, "delete indices on protected cluster prod:\n  noah (10 docs, 1kb)\n", out.String())
+			}
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDeleteWithYes(t *testing.T) {
+	mock := &fake.MockEsResponse{
+		ResponseString: `{"acknowledged":true}`,
+	}
+	for _, c := range []string{
+		"index delete noah --yes",
+		"snapshot delete snap-1 --repo backup --yes",
+		"repo delete backup -y",
+		"user delete noah --yes",
+		"role delete noah --yes",
+	} {
+		_, err := executeCommand(c, mock)
+		require.NoError(t, err, c)
+	}
+}
+
+func TestDeleteOnProtectedCluster(t *testing.T) {
+	defer func() { readConfirmation = es.Prompt }()
+	readConfirmation = func(string) (string, error) { return "qa", nil }
+	protected := &es.ClusterInfo{Url: TestUrl, Username: TestUsername, Password: TestPassword, Protected: true}
+
+	newMock := func() *fake.MockEsRecorder {
+		return &fake.MockEsRecorder{
+			Routes:         fake.MockEsRoutes{"/_cat/indices/noah": `[{"index":"noah","docs.count":"10","store.size":"1kb"}]`},
+			ResponseString: `{"acknowledged":true}`,
+		}
+	}
+	mock := newMock()
+	_, err := executeCommandWithInfo("index delete noah", mock, protected)
+	require.EqualError(t, err, `"qa" is not the cluster name, nothing was done`)
+	require.Equal(t, []string{"GET /_cat/indices/noah"}, mock.Calls())
+
+	mock = newMock()
+	_, err = executeCommandWithInfo("index delete noah --yes", mock, protected)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE /noah"}, mock.Calls())
+
+	mock = newMock()
+	_, err = executeCommand("index delete noah", mock)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE /noah"}, mock.Calls())
+}
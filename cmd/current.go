@@ -3,7 +3,7 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/toughnoah/blackbean/pkg/es"
 	"io"
 )
 
@@ -15,7 +15,8 @@ func current(out io.Writer) *cobra.Command {
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: noCompletions,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Fprintf(out, "current using cluster: %s\n\n", viper.Get("current"))
+			cluster, _ := es.CurrentCluster()
+			fmt.Fprintf(out, "current using cluster: %s\n\n", cluster)
 		},
 	}
 	return command
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	dryRunHeader = "X-Blackbean-Dry-Run"
+
+	diffAdded   = "\033[32m"
+	diffRemoved = "\033[31m"
+	diffHunk    = "\033[36m"
+	diffReset   = "\033[0m"
+)
+
+var (
+	dryRun bool
+
+	settingsPath = regexp.MustCompile(`^/_cluster/settings$`)
+	// the template, role and user apis answer a GET with {"<name>": <what was put>}
+	namedPath = regexp.MustCompile(`^/(_template|_security/role|_security/user)/([^/]+)$`)
+	aliasPath = regexp.MustCompile(`^/([^/]+)/_alias(?:es)?/([^/]+)$`)
+)
+
+// dryRunTransport prints the requests which would change the cluster instead of
+// sending them, together with a diff of the current and proposed state when
+// the changed resource can be read back. Read requests are sent as usual.
+type dryRunTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if es.IsReadRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	fmt.Fprintln(t.out, req.Method, req.URL.RequestURI())
+	if len(body) != 0 {
+		fmt.Fprintln(t.out, strings.TrimRight(string(prettyJSON(redactBody(body))), "\n"))
+	}
+	if err := t.diff(req, body); err != nil {
+		return nil, errors.Wrap(err, "failed to diff against the current state")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{dryRunHeader: []string{"true"}, "Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+		Request:    req,
+	}, nil
+}
+
+// diff prints a unified diff of the current and proposed state of settings, templates, roles, users and aliases.
+func (t *dryRunTransport) diff(req *http.Request, body []byte) error {
+	var (
+		current, proposed interface{}
+		err               error
+		path              = req.URL.Path
+		deleted           = req.Method == http.MethodDelete
+	)
+	switch {
+	case settingsPath.MatchString(path):
+		if current, err = t.get(req, path+"?flat_settings=true"); err != nil {
+			return err
+		}
+		proposed, err = proposedSettings(current, body)
+	case namedPath.MatchString(path):
+		name := namedPath.FindStringSubmatch(path)[2]
+		if current, err = t.get(req, path); err != nil {
+			return err
+		}
+		if m, ok := current.(map[string]interface{}); ok {
+			current = m[name]
+		}
+		if !deleted {
+			proposed, err = decodeJSON(body)
+		}
+		proposed = redactPassword(proposed)
+	case aliasPath.MatchString(path):
+		m := aliasPath.FindStringSubmatch(path)
+		indices, name := m[1], m[2]
+		if current, err = t.get(req, "/"+indices+"/_alias/"+name); err != nil {
+			return err
+		}
+		aliases := make(map[string]interface{})
+		if !deleted {
+			var alias interface{} = map[string]interface{}{}
+			if len(body) != 0 {
+				if alias, err = decodeJSON(body); err != nil {
+					return err
+				}
+			}
+			for _, index := range splitWords(indices) {
+				aliases[index] = map[string]interface{}{"aliases": map[string]interface{}{name: alias}}
+			}
+		}
+		proposed = aliases
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(t.out, colorDiff(unifiedDiff(current, proposed)))
+	return nil
+}
+
+// get reads the current state, a missing resource is nil.
+func (t *dryRunTransport) get(orig *http.Request, uri string) (interface{}, error) {
+	u := *orig.URL
+	u.RawQuery = ""
+	if i := strings.Index(uri, "?"); i >= 0 {
+		uri, u.RawQuery = uri[:i], uri[i+1:]
+	}
+	u.Path, u.RawPath = uri, ""
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(orig.Context())
+	req.Header = orig.Header.Clone()
+	req.Header.Del("Content-Type")
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, errors.Errorf("[%d %s] %s", res.StatusCode, http.StatusText(res.StatusCode), strings.TrimSpace(string(body)))
+	}
+	return decodeJSON(body)
+}
+
+// proposedSettings applies a put settings body to the current flat settings,
+// a null value resets a setting, or all settings below a key ending with ".*".
+func proposedSettings(current interface{}, body []byte) (interface{}, error) {
+	put, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	cur, _ := current.(map[string]interface{})
+	putMap, _ := put.(map[string]interface{})
+	proposed := make(map[string]interface{})
+	for _, scope := range []string{persistentSettings, transientSettings} {
+		settings := make(map[string]interface{})
+		if m, ok := cur[scope].(map[string]interface{}); ok {
+			for k, v := range m {
+				settings[k] = v
+			}
+		}
+		changes := make(map[string]interface{})
+		flattenSettings("", putMap[scope], changes)
+		for key, v := range changes {
+			if v != nil {
+				settings[key] = v
+				continue
+			}
+			delete(settings, key)
+			if strings.HasSuffix(key, ".*") {
+				prefix := strings.TrimSuffix(key, "*")
+				for k := range settings {
+					if strings.HasPrefix(k, prefix) {
+						delete(settings, k)
+					}
+				}
+			}
+		}
+		proposed[scope] = settings
+	}
+	return proposed, nil
+}
+
+// redactPassword masks the passwords, and any other secret, of a user or profile body.
+func redactPassword(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k := range m {
+		if isSecretField(k) {
+			m[k] = "******"
+		}
+	}
+	return m
+}
+
+func isSecretField(key string) bool {
+	return key == "password_hash" || es.IsSecretKey(key)
+}
+
+// redactBody masks the secrets of a json body before it is printed, other bodies are kept as they are.
+func redactBody(body []byte) []byte {
+	v, err := decodeJSON(body)
+	if err != nil {
+		return body
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return body
+	}
+	secret := false
+	for k := range m {
+		secret = secret || isSecretField(k)
+	}
+	if !secret {
+		return body
+	}
+	redacted, err := json.Marshal(redactPassword(m))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "body is not json")
+	}
+	return v, nil
+}
+
+// prettyJSON indents a json body, other bodies like ndjson are kept as they are.
+func prettyJSON(body []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+func unifiedDiff(current, proposed interface{}) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(indentJSON(current)),
+		B:        difflib.SplitLines(indentJSON(proposed)),
+		FromFile: "current",
+		ToFile:   "proposed",
+		Context:  3,
+	})
+	if diff == "" {
+		return "no changes\n"
+	}
+	return diff
+}
+
+// indentJSON renders a value with sorted keys, a missing value renders as nothing.
+func indentJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b) + "\n"
+}
+
+// colorDiff colors added and removed lines, unless NO_COLOR is set.
+func colorDiff(diff string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return diff
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			lines[i] = diffAdded + strings.TrimSuffix(line, "\n") + diffReset + "\n"
+		case strings.HasPrefix(line, "-"):
+			lines[i] = diffRemoved + strings.TrimSuffix(line, "\n") + diffReset + "\n"
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = diffHunk + strings.TrimSuffix(line, "\n") + diffReset + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// isDryRun reports whether a response was made up by the dry run transport.
+func isDryRun(header http.Header) bool {
+	return header.Get(dryRunHeader) != ""
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+func TestDryRunTransport(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		routes  fake.MockEsRoutes
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "settings",
+			method: http.MethodPut,
+			path:   "/_cluster/settings?pretty",
+			body:   `{"persistent":{"cluster":{"routing":{"allocation":{"enable":"primaries"}}},"indices.recovery.max_bytes_per_sec":null}}`,
+			routes: fake.MockEsRoutes{"/_cluster/settings": `{"persistent":{"cluster.routing.allocation.enable":"all","indices.recovery.max_bytes_per_sec":"100mb"},"transient":{}}`},
+			want: []string{
+				"PUT /_cluster/settings?pretty\n{\n  \"persistent\": {\n",
+				"--- current\n+++ proposed\n",
+				diffRemoved + `-    "cluster.routing.allocation.enable": "all",` + diffReset,
+				diffRemoved + `-    "indices.recovery.max_bytes_per_sec": "100mb"` + diffReset,
+				diffAdded + `+    "cluster.routing.allocation.enable": "primaries"` + diffReset,
+			},
+		},
+		{
+			name:   "new role",
+			method: http.MethodPut,
+			path:   "/_security/role/noah",
+			body:   `{"cluster":["monitor"]}`,
+			routes: fake.MockEsRoutes{},
+			want: []string{
+				"PUT /_security/role/noah\n",
+				diffAdded + `+  "cluster": [` + diffReset,
+			},
+		},
+		{
+			name:   "user password",
+			method: http.MethodPut,
+			path:   "/_security/user/noah",
+			body:   `{"password":"bulldog","roles":["superuser"]}`,
+			routes: fake.MockEsRoutes{"/_security/user/noah": `{"noah":{"roles":["monitor"],"enabled":true}}`},
+			want: []string{
+				diffAdded + `+  "password": "******",` + diffReset,
+				diffRemoved + `-    "monitor"` + diffReset,
+			},
+			notWant: []string{"bulldog"},
+		},
+		{
+			name:   "delete template",
+			method: http.MethodDelete,
+			path:   "/_template/noah",
+			routes: fake.MockEsRoutes{"/_template/noah": `{"noah":{"order":0,"index_patterns":["noah-*"]}}`},
+			want: []string{
+				"DELETE /_template/noah\n",
+				diffRemoved + `-  "order": 0` + diffReset,
+			},
+		},
+		{
+			name:   "unchanged alias",
+			method: http.MethodPut,
+			path:   "/noah/_alias/bulldog",
+			routes: fake.MockEsRoutes{"/noah/_alias/bulldog": `{"noah":{"aliases":{"bulldog":{}}}}`},
+			want:   []string{"PUT /noah/_alias/bulldog\nno changes\n"},
+		},
+		{
+			name:   "no counterpart",
+			method: http.MethodPost,
+			path:   "/noah/_doc",
+			body:   "{\"name\":\"blackbean\"}",
+			routes: fake.MockEsRoutes{},
+			want:   []string{"POST /noah/_doc\n{\n  \"name\": \"blackbean\"\n}\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			tr := &dryRunTransport{next: tc.routes, out: out}
+			req, err := http.NewRequest(tc.method, "http://localhost:9200"+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			res, err := tr.RoundTrip(req)
+			require.NoError(t, err)
+			require.True(t, isDryRun(res.Header))
+			for _, want := range tc.want {
+				require.Contains(t, out.String(), want)
+			}
+			for _, notWant := range tc.notWant {
+				require.NotContains(t, out.String(), notWant)
+			}
+		})
+	}
+}
+
+func TestDryRunTransportReads(t *testing.T) {
+	out := new(bytes.Buffer)
+	tr := &dryRunTransport{next: fake.MockEsRoutes{"/noah/_search": `{"hits":{}}`}, out: out}
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:9200/noah/_search", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	res, err := tr.RoundTrip(req)
+	require.NoError(t, err)
+	require.False(t, isDryRun(res.Header))
+	require.Empty(t, out.String())
+}
+
+func TestDryRunCommand(t *testing.T) {
+	mock := fake.MockEsRoutes{"/_cluster/settings": `{"persistent":{},"transient":{}}`}
+	o, err := executeCommand("--dry-run settings set cluster.max_shards_per_node=2000", mock)
+	require.NoError(t, err)
+	require.Contains(t, o, "PUT /_cluster/settings?pretty\n")
+	require.Contains(t, o, diffAdded+`+    "cluster.max_shards_per_node": "2000"`+diffReset)
+	require.NotContains(t, o, "[200 OK]")
+}
+
+func TestDryRunUserPassword(t *testing.T) {
+	mock := &fake.MockEsResponse{
+		ResponseString: `{"superuser":{"cluster":["all"]}}`,
+	}
+	o, err := executeCommand("--dry-run user create noah-test --roles=superuser", mock)
+	require.NoError(t, err)
+	require.Contains(t, o, "PUT /_security/user/noah-test")
+	require.Contains(t, o, `"password": "******"`)
+	require.NotContains(t, o, `"password": "`+TestPassword+`"`)
+	require.NotContains(t, o, `"password":"`+TestPassword+`"`)
+}
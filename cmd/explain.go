@@ -3,7 +3,6 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/spf13/cobra"
@@ -30,10 +29,10 @@ func explain(cli *elasticsearch.Client, out io.Writer, args []string) *cobra.Com
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := e.allocationExplain(args, shard, node, primary)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -77,6 +76,15 @@ func (e *Explain) allocationExplain(args []string, shard, node string, primary b
 	return e.Client.Cluster.AllocationExplain(e.Client.Cluster.AllocationExplain.WithPretty())
 }
 
+// explainShard explains why a single shard copy is, or is not, allocated.
+func (e *Explain) explainShard(body *ExplainBody) (*esapi.Response, error) {
+	bytesBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return e.Client.Cluster.AllocationExplain(e.Client.Cluster.AllocationExplain.WithBody(bytes.NewReader(bytesBody)))
+}
+
 func ArgSet(args []string) bool {
 	return len(args) >= 2
 }
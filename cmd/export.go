@@ -0,0 +1,506 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"golang.org/x/term"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errPITUnsupported sends an export back to scroll, on clusters older than point in time.
+var errPITUnsupported = errors.New("point in time is not supported")
+
+func exportIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		req     = new(es.RequestBody)
+		i       = Indices{client: cli}
+		e       = Exporter{client: cli}
+		file    string
+		gz      bool
+		fields  []string
+		command = &cobra.Command{
+			Use:   "export [index]",
+			Short: "export all the documents of an index as ndjson",
+			Long:  "export all the documents of an index as ndjson, one {\"_index\",\"_id\",\"_source\"} per line ... wordless",
+			Example: `  blackbean index export noah --out noah.ndjson
+  blackbean index export noah -d '{"query":{"term":{"name":"blackbean"}}}' --fields name,age --out noah.ndjson.gz
+  blackbean index export noah --slices 4 --out noah.ndjson`,
+			Args: cobra.ExactArgs(1),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) != 0 {
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				}
+				return i.getAllIndices(), cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if e.size < 1 || e.slices < 1 {
+					return errors.New("--size and --slices must be at least 1")
+				}
+				e.index = args[0]
+				e.fields = fields
+				if !es.NoRawRequestBodySet(cmd) {
+					body, err := es.GetRawRequestBody(req)
+					if err != nil {
+						return err
+					}
+					if e.query, err = exportQuery(body); err != nil {
+						return err
+					}
+				}
+				// the documents may go to stdout, so anything else goes to stderr then
+				dst, report := out, io.Writer(os.Stderr)
+				if file != "" {
+					f, err := os.Create(file)
+					if err != nil {
+						return errors.Wrap(err, "failed to create export file")
+					}
+					defer f.Close()
+					dst, report = f, out
+				}
+				w := newExportWriter(dst, gz || strings.HasSuffix(file, ".gz"))
+				e.write = w.write
+				if term.IsTerminal(int(os.Stderr.Fd())) {
+					e.progress = &progressBar{out: os.Stderr}
+				}
+				n, err := e.export(context.Background())
+				if e.progress != nil {
+					e.progress.finish()
+				}
+				if cerr := w.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					return errors.Wrapf(err, "export stopped after %d docs", n)
+				}
+				if file != "" {
+					fmt.Fprintf(report, "exported %d docs of %s to %s\n", n, e.index, file)
+				}
+				return nil
+			},
+		}
+	)
+	es.AddRequestBodyFlag(command, req)
+	f := command.Flags()
+	f.StringVar(&file, "out", "", "file to write the documents to, stdout if unset.")
+	f.BoolVar(&gz, "gzip", false, "gzip the documents, implied by an --out ending in .gz.")
+	f.StringSliceVar(&fields, "fields", nil, "only export these fields of _source, comma separated, wildcards allowed.")
+	f.IntVar(&e.slices, "slices", 1, "walk the index with this many sliced scrolls in parallel.")
+	f.IntVar(&e.size, "size", 1000, "documents fetched per request.")
+	f.DurationVar(&e.keepAlive, "keep_alive", time.Minute, "how long es keeps the search context between two requests.")
+	return command
+}
+
+// exportQuery takes the query of a search body, or the body itself when it is a bare query.
+func exportQuery(body []byte) (json.RawMessage, error) {
+	var search map[string]json.RawMessage
+	if err := json.Unmarshal(body, &search); err != nil {
+		return nil, errors.Wrap(err, "bad export query")
+	}
+	if q, ok := search["query"]; ok {
+		return q, nil
+	}
+	if len(search) == 0 {
+		return nil, nil
+	}
+	return body, nil
+}
+
+// Exporter walks every document of an index matching a query, a page at a time,
+// so that memory does not grow with the index.
+type Exporter struct {
+	client    *elasticsearch.Client
+	index     string
+	query     json.RawMessage
+	fields    []string
+	size      int
+	slices    int
+	keepAlive time.Duration
+	write     func([]exportHit) error
+	progress  *progressBar
+	exported  int64
+}
+
+type exportHit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Source json.RawMessage `json:"_source,omitempty"`
+	Sort   json.RawMessage `json:"sort,omitempty"`
+}
+
+type exportPage struct {
+	ScrollID string `json:"_scroll_id"`
+	PitID    string `json:"pit_id"`
+	Hits     struct {
+		Total exportTotal `json:"total"`
+		Hits  []exportHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// exportTotal is hits.total, an object since es 7 and a number before.
+type exportTotal int64
+
+func (t *exportTotal) UnmarshalJSON(b []byte) error {
+	var total struct {
+		Value int64 `json:"value"`
+	}
+	if err := json.Unmarshal(b, &total); err == nil {
+		*t = exportTotal(total.Value)
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*t = exportTotal(n)
+	return nil
+}
+
+// export walks the index with a point in time, falling back to scroll on
+// clusters without one, or with parallel sliced scrolls for more than one slice.
+func (e *Exporter) export(ctx context.Context) (int64, error) {
+	if e.slices > 1 {
+		err := e.scrollSlices(ctx)
+		return atomic.LoadInt64(&e.exported), err
+	}
+	err := e.pit(ctx)
+	if err == errPITUnsupported {
+		err = e.scroll(ctx, 0)
+	}
+	return atomic.LoadInt64(&e.exported), err
+}
+
+func (e *Exporter) scrollSlices(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+	)
+	for slice := 0; slice < e.slices; slice++ {
+		wg.Add(1)
+		go func(slice int) {
+			defer wg.Done()
+			if serr := e.scroll(ctx, slice); serr != nil {
+				// the first failure stops the other slices
+				once.Do(func() {
+					err = errors.Wrapf(serr, "slice %d", slice)
+					cancel()
+				})
+			}
+		}(slice)
+	}
+	wg.Wait()
+	return err
+}
+
+func (e *Exporter) pit(ctx context.Context) error {
+	res, err := esapi.OpenPointInTimeRequest{Index: []string{e.index}, KeepAlive: esDuration(e.keepAlive)}.Do(ctx, e.client)
+	if err != nil {
+		return err
+	}
+	if pitUnsupported(res) {
+		res.Body.Close()
+		return errPITUnsupported
+	}
+	if err := es.CheckResponse(res); err != nil {
+		return err
+	}
+	var opened struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&opened)
+	res.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to parse point in time")
+	}
+	id := opened.ID
+	defer func() { e.closePIT(id) }()
+
+	var after json.RawMessage
+	for first := true; ; first = false {
+		body, err := e.searchBody(map[string]interface{}{
+			"pit":          map[string]string{"id": id, "keep_alive": esDuration(e.keepAlive)},
+			"sort":         []string{"_shard_doc"},
+			"search_after": after,
+		})
+		if err != nil {
+			return err
+		}
+		res, err := e.client.Search(e.client.Search.WithContext(ctx), e.client.Search.WithBody(bytes.NewReader(body)))
+		if err != nil {
+			return err
+		}
+		if first && pitUnsupported(res) {
+			// a point in time without the _shard_doc tiebreaker cannot be paged
+			res.Body.Close()
+			return errPITUnsupported
+		}
+		page, err := e.readPage(res, first)
+		if err != nil {
+			return err
+		}
+		if page.PitID != "" {
+			id = page.PitID
+		}
+		hits := page.Hits.Hits
+		if len(hits) == 0 {
+			return nil
+		}
+		after = hits[len(hits)-1].Sort
+		if err := e.emit(hits); err != nil {
+			return err
+		}
+		if len(hits) < e.size {
+			return nil
+		}
+	}
+}
+
+func (e *Exporter) closePIT(id string) {
+	body, _ := json.Marshal(map[string]string{"id": id})
+	res, err := esapi.ClosePointInTimeRequest{Body: bytes.NewReader(body)}.Do(context.Background(), e.client)
+	if err == nil {
+		err = es.CheckResponse(res)
+		res.Body.Close()
+	}
+	if err != nil {
+		// es frees it anyway once keep_alive is over
+		log.Printf("failed to close point in time: %s", err)
+	}
+}
+
+func (e *Exporter) scroll(ctx context.Context, slice int) error {
+	extra := map[string]interface{}{"sort": []string{"_doc"}}
+	if e.slices > 1 {
+		extra["slice"] = map[string]int{"id": slice, "max": e.slices}
+	}
+	body, err := e.searchBody(extra)
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Search(
+		e.client.Search.WithContext(ctx),
+		e.client.Search.WithIndex(e.index),
+		e.client.Search.WithScroll(e.keepAlive),
+		e.client.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return err
+	}
+	page, err := e.readPage(res, true)
+	if err != nil {
+		return err
+	}
+	id := page.ScrollID
+	defer func() { e.clearScroll(id) }()
+	for {
+		hits := page.Hits.Hits
+		if len(hits) == 0 {
+			return nil
+		}
+		if err := e.emit(hits); err != nil {
+			return err
+		}
+		res, err := e.client.Scroll(
+			e.client.Scroll.WithContext(ctx),
+			e.client.Scroll.WithScrollID(id),
+			e.client.Scroll.WithScroll(e.keepAlive),
+		)
+		if err != nil {
+			return err
+		}
+		if page, err = e.readPage(res, false); err != nil {
+			return err
+		}
+		if page.ScrollID != "" {
+			id = page.ScrollID
+		}
+	}
+}
+
+func (e *Exporter) clearScroll(id string) {
+	if id == "" {
+		return
+	}
+	res, err := e.client.ClearScroll(e.client.ClearScroll.WithScrollID(id))
+	if err == nil {
+		err = es.CheckResponse(res)
+		res.Body.Close()
+	}
+	if err != nil {
+		log.Printf("failed to clear scroll: %s", err)
+	}
+}
+
+// searchBody is the query and source filtering of the export, along with extra.
+func (e *Exporter) searchBody(extra map[string]interface{}) ([]byte, error) {
+	body := map[string]interface{}{"size": e.size}
+	if e.query != nil {
+		body["query"] = e.query
+	}
+	if len(e.fields) != 0 {
+		body["_source"] = e.fields
+	}
+	for k, v := range extra {
+		if raw, ok := v.(json.RawMessage); ok && raw == nil {
+			continue
+		}
+		body[k] = v
+	}
+	if _, paging := body["search_after"]; !paging {
+		// only the first page counts the hits, for the progress bar
+		body["track_total_hits"] = true
+	}
+	return json.Marshal(body)
+}
+
+func (e *Exporter) readPage(res *esapi.Response, first bool) (*exportPage, error) {
+	defer res.Body.Close()
+	if err := es.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	page := new(exportPage)
+	if err := json.NewDecoder(res.Body).Decode(page); err != nil {
+		return nil, errors.Wrap(err, "failed to parse search response")
+	}
+	if first && e.progress != nil {
+		e.progress.addTotal(int64(page.Hits.Total))
+	}
+	return page, nil
+}
+
+func (e *Exporter) emit(hits []exportHit) error {
+	if err := e.write(hits); err != nil {
+		return err
+	}
+	n := atomic.AddInt64(&e.exported, int64(len(hits)))
+	if e.progress != nil {
+		e.progress.set(n)
+	}
+	return nil
+}
+
+// pitUnsupported tells a cluster which does not know point in time, or its
+// _shard_doc sort, from a real failure such as a missing index or privilege.
+func pitUnsupported(res *esapi.Response) bool {
+	switch res.StatusCode {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return true
+	}
+	return false
+}
+
+// esDuration formats d as an es time unit.
+func esDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+// exportWriter writes hits as ndjson, safe for the concurrent slices of an export.
+type exportWriter struct {
+	mu  sync.Mutex
+	buf *bufio.Writer
+	gz  *gzip.Writer
+}
+
+func newExportWriter(w io.Writer, gz bool) *exportWriter {
+	ew := new(exportWriter)
+	if gz {
+		ew.gz = gzip.NewWriter(w)
+		w = ew.gz
+	}
+	ew.buf = bufio.NewWriter(w)
+	return ew
+}
+
+func (w *exportWriter) write(hits []exportHit) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, h := range hits {
+		h.Sort = nil
+		line, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		if _, err := w.buf.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes what is buffered, it does not close the underlying writer.
+func (w *exportWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// progressBar redraws a single line with the docs done out of total.
+type progressBar struct {
+	mu    sync.Mutex
+	out   io.Writer
+	total int64
+	done  int64
+}
+
+const progressWidth = 30
+
+func (p *progressBar) addTotal(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+}
+
+func (p *progressBar) set(done int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if done > p.done {
+		p.done = done
+	}
+	fmt.Fprint(p.out, "\r"+p.line())
+}
+
+func (p *progressBar) line() string {
+	if p.total <= 0 {
+		return fmt.Sprintf("%d docs", p.done)
+	}
+	ratio := float64(p.done) / float64(p.total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * progressWidth)
+	return fmt.Sprintf("[%s%s] %d/%d docs %3d%%",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), p.done, p.total, int(ratio*100))
+}
+
+func (p *progressBar) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exportTransport serves an index of three docs, with or without point in time.
+type exportTransport struct {
+	noPIT    bool
+	requests []string
+}
+
+func (t *exportTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body map[string]json.RawMessage
+	if req.Body != nil {
+		_ = json.NewDecoder(req.Body).Decode(&body)
+	}
+	t.requests = append(t.requests, req.Method+" "+req.URL.Path)
+	respond := func(code int, s string) (*http.Response, error) {
+		return &http.Response{StatusCode: code, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(s))}, nil
+	}
+	page1 := `"total":{"value":3,"relation":"eq"},"hits":[{"_index":"noah","_id":"1","_source":{"name":"a"},"sort":[1]},{"_index":"noah","_id":"2","_source":{"name":"b"},"sort":[2]}]`
+	page2 := `"total":{"value":3,"relation":"eq"},"hits":[{"_index":"noah","_id":"3","_source":{"name":"c"},"sort":[3]}]`
+	switch req.Method + " " + req.URL.Path {
+	case "POST /noah/_pit":
+		if t.noPIT {
+			return respond(http.StatusBadRequest, `{"error":{"type":"illegal_argument_exception"}}`)
+		}
+		return respond(http.StatusOK, `{"id":"pit-1"}`)
+	case "POST /_search":
+		if _, ok := body["search_after"]; ok {
+			return respond(http.StatusOK, `{"pit_id":"pit-2","hits":{`+page2+`}}`)
+		}
+		return respond(http.StatusOK, `{"pit_id":"pit-1","hits":{`+page1+`}}`)
+	case "POST /noah/_search":
+		return respond(http.StatusOK, `{"_scroll_id":"s1","hits":{`+page1+`}}`)
+	case "POST /_search/scroll", "GET /_search/scroll":
+		if len(t.requests) > 3 {
+			return respond(http.StatusOK, `{"_scroll_id":"s1","hits":{"total":{"value":3},"hits":[]}}`)
+		}
+		return respond(http.StatusOK, `{"_scroll_id":"s1","hits":{`+page2+`}}`)
+	}
+	return respond(http.StatusOK, `{}`)
+}
+
+func TestIndexExport(t *testing.T) {
+	want := `{"_index":"noah","_id":"1","_source":{"name":"a"}}
+{"_index":"noah","_id":"2","_source":{"name":"b"}}
+{"_index":"noah","_id":"3","_source":{"name":"c"}}
+`
+	mock := &exportTransport{}
+	o, err := executeCommand("index export noah --size 2", mock)
+	require.NoError(t, err)
+	require.Equal(t, want, o)
+	require.Equal(t, []string{"POST /noah/_pit", "POST /_search", "POST /_search", "DELETE /_pit"}, mock.requests)
+
+	mock = &exportTransport{noPIT: true}
+	file := filepath.Join(t.TempDir(), "noah.ndjson.gz")
+	o, err = executeCommand("index export noah --size 2 --out "+file, mock)
+	require.NoError(t, err)
+	require.Equal(t, "exported 3 docs of noah to "+file+"\n", o)
+	require.Equal(t, "POST /noah/_pit", mock.requests[0])
+	require.Equal(t, "POST /noah/_search", mock.requests[1])
+	require.Contains(t, mock.requests[len(mock.requests)-1], "/_search/scroll")
+	raw, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	docs, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, want, string(docs))
+}
+
+func TestExportQuery(t *testing.T) {
+	q, err := exportQuery([]byte(`{"query":{"term":{"name":"a"}},"size":10}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"term":{"name":"a"}}`, string(q))
+	q, err = exportQuery([]byte(`{"term":{"name":"a"}}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"term":{"name":"a"}}`, string(q))
+	q, err = exportQuery([]byte(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, q)
+	_, err = exportQuery([]byte(`[]`))
+	require.Error(t, err)
+}
+
+func TestExportSearchBody(t *testing.T) {
+	e := &Exporter{size: 500, query: json.RawMessage(`{"match_all":{}}`), fields: []string{"name", "age"}, slices: 2}
+	body, err := e.searchBody(map[string]interface{}{"sort": []string{"_doc"}, "search_after": json.RawMessage(nil)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"size":500,"query":{"match_all":{}},"_source":["name","age"],"sort":["_doc"],"track_total_hits":true}`, string(body))
+	body, err = e.searchBody(map[string]interface{}{"search_after": json.RawMessage(`[2]`)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"size":500,"query":{"match_all":{}},"_source":["name","age"],"search_after":[2]}`, string(body))
+}
+
+func TestExportTotal(t *testing.T) {
+	var page exportPage
+	require.NoError(t, json.Unmarshal([]byte(`{"hits":{"total":{"value":42,"relation":"eq"}}}`), &page))
+	require.Equal(t, exportTotal(42), page.Hits.Total)
+	require.NoError(t, json.Unmarshal([]byte(`{"hits":{"total":7}}`), &page))
+	require.Equal(t, exportTotal(7), page.Hits.Total)
+}
+
+func TestExportWriter(t *testing.T) {
+	hits := []exportHit{{Index: "noah", ID: "1", Source: json.RawMessage(`{"a": 1}`), Sort: json.RawMessage(`[1]`)}}
+	buf := new(bytes.Buffer)
+	w := newExportWriter(buf, false)
+	require.NoError(t, w.write(hits))
+	require.NoError(t, w.Close())
+	require.Equal(t, `{"_index":"noah","_id":"1","_source":{"a":1}}`+"\n", buf.String())
+
+	buf.Reset()
+	w = newExportWriter(buf, true)
+	require.NoError(t, w.write(hits))
+	require.NoError(t, w.Close())
+	gz, err := gzip.NewReader(buf)
+	require.NoError(t, err)
+	plain, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, `{"_index":"noah","_id":"1","_source":{"a":1}}`+"\n", string(plain))
+}
+
+func TestProgressBar(t *testing.T) {
+	out := new(bytes.Buffer)
+	p := &progressBar{out: out}
+	p.set(5)
+	require.Equal(t, "\r5 docs", out.String())
+	p.addTotal(10)
+	p.addTotal(10)
+	p.set(5)
+	require.Equal(t, "[=======                       ] 5/20 docs  25%", p.line())
+	p.set(20)
+	require.Equal(t, "[==============================] 20/20 docs 100%", p.line())
+}
+
+func TestEsDuration(t *testing.T) {
+	require.Equal(t, "60s", esDuration(60e9))
+	require.Equal(t, "1500ms", esDuration(15e8))
+}
@@ -0,0 +1,611 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// formats of index import
+const (
+	importNDJSON = "ndjson"
+	importBulk   = "bulk"
+	importCSV    = "csv"
+)
+
+// bulkBackoff is how long to wait before the attempt-th retry of items rejected with 429.
+var bulkBackoff = func(attempt int) time.Duration {
+	d := time.Second << uint(attempt)
+	if d > 30*time.Second {
+		return 30 * time.Second
+	}
+	return d
+}
+
+func importIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		i       = Indices{client: cli}
+		im      = Importer{client: cli}
+		file    string
+		format  string
+		command = &cobra.Command{
+			Use:   "import [index]",
+			Short: "import documents from ndjson, bulk or csv files with concurrent bulk requests",
+			Long:  "import documents from ndjson, bulk or csv files with concurrent bulk requests ... wordless",
+			Example: `  blackbean index import noah -f noah.ndjson.gz
+  blackbean index import noah -f people.csv --workers 4 --batch_size 5000
+  blackbean index import -f requests.bulk --format bulk --reject_file rejected.ndjson`,
+			Args: cobra.MaximumNArgs(1),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) != 0 {
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				}
+				return i.getAllIndices(), cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if im.batchDocs < 1 || im.batchBytes < 1 || im.workers < 1 {
+					return errors.New("--batch_size, --batch_bytes and --workers must be at least 1")
+				}
+				if im.op != "index" && im.op != "create" {
+					return errors.Errorf("bad --op %q, one of index, create", im.op)
+				}
+				if len(args) != 0 {
+					im.index = args[0]
+				}
+				in, err := openImportFile(file)
+				if err != nil {
+					return err
+				}
+				defer in.Close()
+				if format == "" {
+					format = importFormat(file)
+				}
+				if im.index == "" && format == importCSV {
+					return errors.New("csv rows have no _index, give the [index] to import to")
+				}
+				reader, err := newItemReader(in, format, im.index == "", im.op)
+				if err != nil {
+					return err
+				}
+				if im.rejectFile == "" {
+					im.rejectFile = defaultRejectFile(file)
+				}
+				im.rejects = &rejectWriter{path: im.rejectFile}
+				stats, err := im.run(context.Background(), reader)
+				fmt.Fprintln(out, stats.summary(im.rejects))
+				if cerr := im.rejects.Close(); err == nil && cerr != nil {
+					err = errors.Wrap(cerr, "failed to write reject file")
+				}
+				if err != nil {
+					return err
+				}
+				if stats.failed != 0 {
+					return errors.Errorf("%d docs failed, see %s", stats.failed, im.rejectFile)
+				}
+				return nil
+			},
+		}
+	)
+	f := command.Flags()
+	f.StringVarP(&file, "filename", "f", "", "file to import, - for stdin, gzipped when ending in .gz.")
+	_ = command.MarkFlagRequired("filename")
+	f.StringVar(&format, "format", "", "one of ndjson|bulk|csv, guessed from the file extension by default, ndjson when unsure.")
+	f.StringVar(&im.op, "op", "index", "bulk action of ndjson and csv docs, index or create.")
+	f.StringVar(&im.pipeline, "pipeline", "", "ID of the pipeline to use to preprocess incoming documents.")
+	f.IntVar(&im.batchDocs, "batch_size", 1000, "documents per bulk request at most.")
+	f.IntVar(&im.batchBytes, "batch_bytes", 5<<20, "bytes per bulk request at most, keep it below http.max_content_length.")
+	f.IntVar(&im.workers, "workers", 2, "bulk requests sent in parallel.")
+	f.IntVar(&im.maxRetries, "max_retries", 5, "times to retry the items rejected with 429, backing off exponentially.")
+	f.StringVar(&im.rejectFile, "reject_file", "", "file the failed items are written to with their error, <filename>.rejected.ndjson by default.")
+	return command
+}
+
+func openImportFile(file string) (io.ReadCloser, error) {
+	var in io.ReadCloser = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open import file")
+		}
+		in = f
+	}
+	if !strings.HasSuffix(file, ".gz") {
+		return in, nil
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		in.Close()
+		return nil, errors.Wrap(err, "failed to read gzipped import file")
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, in}, nil
+}
+
+func importFormat(file string) string {
+	switch filepath.Ext(strings.TrimSuffix(file, ".gz")) {
+	case ".csv":
+		return importCSV
+	case ".bulk":
+		return importBulk
+	}
+	return importNDJSON
+}
+
+func defaultRejectFile(file string) string {
+	if file == "-" {
+		return "rejected.ndjson"
+	}
+	return strings.TrimSuffix(file, ".gz") + ".rejected.ndjson"
+}
+
+// bulkItem is one action of a bulk request, with its source unless a delete.
+type bulkItem struct {
+	action json.RawMessage
+	source json.RawMessage
+}
+
+func (it *bulkItem) size() int {
+	return len(it.action) + len(it.source) + 2
+}
+
+// itemReader yields bulk items until io.EOF. A *badLineError skips a single line.
+type itemReader interface {
+	next() (*bulkItem, error)
+}
+
+type badLineError struct {
+	line int
+	raw  string
+	err  error
+}
+
+func (e *badLineError) Error() string {
+	return fmt.Sprintf("bad line %d: %s", e.line, e.err)
+}
+
+func newItemReader(in io.Reader, format string, keepIndex bool, op string) (itemReader, error) {
+	switch format {
+	case importNDJSON:
+		return &ndjsonReader{lines: newLineReader(in), keepIndex: keepIndex, op: op}, nil
+	case importBulk:
+		return &bulkReader{lines: newLineReader(in)}, nil
+	case importCSV:
+		r := csv.NewReader(in)
+		r.ReuseRecord = true
+		header, err := r.Read()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read csv header")
+		}
+		return &csvReader{csv: r, header: append([]string{}, header...), op: op}, nil
+	}
+	return nil, errors.Errorf("bad --format %q, one of %s, %s, %s", format, importNDJSON, importBulk, importCSV)
+}
+
+// lineReader reads non blank lines of any length.
+type lineReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func newLineReader(in io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(in, 64*1024)}
+}
+
+func (l *lineReader) next() ([]byte, error) {
+	for {
+		line, err := l.r.ReadBytes('\n')
+		if len(line) != 0 {
+			l.n++
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ndjsonReader takes a document per line, or the lines of index export.
+type ndjsonReader struct {
+	lines     *lineReader
+	keepIndex bool
+	op        string
+}
+
+func (r *ndjsonReader) next() (*bulkItem, error) {
+	line, err := r.lines.next()
+	if err != nil {
+		return nil, err
+	}
+	var exported struct {
+		Index  string          `json:"_index"`
+		ID     string          `json:"_id"`
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := json.Unmarshal(line, &exported); err != nil {
+		return nil, &badLineError{line: r.lines.n, raw: string(line), err: err}
+	}
+	if r.keepIndex && exported.Index == "" {
+		// every bulk request would fail with index is missing
+		return nil, errors.Errorf("line %d has no _index, give the [index] to import to", r.lines.n)
+	}
+	meta := map[string]string{}
+	source := json.RawMessage(line)
+	if exported.Source != nil {
+		source = exported.Source
+		if exported.ID != "" {
+			meta["_id"] = exported.ID
+		}
+		if r.keepIndex && exported.Index != "" {
+			meta["_index"] = exported.Index
+		}
+	}
+	action, _ := json.Marshal(map[string]interface{}{r.op: meta})
+	return &bulkItem{action: action, source: source}, nil
+}
+
+// bulkReader takes the action and source lines of a bulk request body.
+type bulkReader struct {
+	lines *lineReader
+}
+
+func (r *bulkReader) next() (*bulkItem, error) {
+	line, err := r.lines.next()
+	if err != nil {
+		return nil, err
+	}
+	var action map[string]json.RawMessage
+	if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+		if err == nil {
+			err = errors.New("want one action per action line")
+		}
+		return nil, &badLineError{line: r.lines.n, raw: string(line), err: err}
+	}
+	item := &bulkItem{action: line}
+	if _, ok := action["delete"]; ok {
+		return item, nil
+	}
+	source, err := r.lines.next()
+	if err == io.EOF {
+		return nil, &badLineError{line: r.lines.n, raw: string(line), err: errors.New("action without source")}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(source) {
+		return nil, &badLineError{line: r.lines.n, raw: string(source), err: errors.New("invalid json source")}
+	}
+	item.source = source
+	return item, nil
+}
+
+// csvReader takes a document per row, the fields named by the header, all values strings.
+type csvReader struct {
+	csv    *csv.Reader
+	header []string
+	op     string
+	n      int
+}
+
+func (r *csvReader) next() (*bulkItem, error) {
+	record, err := r.csv.Read()
+	r.n++
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &badLineError{line: r.n + 1, raw: strings.Join(record, ","), err: err}
+	}
+	doc := make(map[string]string, len(r.header))
+	for k, name := range r.header {
+		doc[name] = record[k]
+	}
+	source, _ := json.Marshal(doc)
+	action, _ := json.Marshal(map[string]interface{}{r.op: map[string]string{}})
+	return &bulkItem{action: action, source: source}, nil
+}
+
+// Importer sends bulk items by batches of bounded docs and bytes over concurrent workers.
+type Importer struct {
+	client     *elasticsearch.Client
+	index      string
+	op         string
+	pipeline   string
+	batchDocs  int
+	batchBytes int
+	workers    int
+	maxRetries int
+	rejectFile string
+	rejects    *rejectWriter
+}
+
+type importStats struct {
+	read     int64
+	imported int64
+	failed   int64
+	retried  int64
+	elapsed  time.Duration
+}
+
+func (s *importStats) summary(rejects *rejectWriter) string {
+	rate := float64(s.imported)
+	if s.elapsed > 0 {
+		rate = float64(s.imported) / s.elapsed.Seconds()
+	}
+	msg := fmt.Sprintf("imported %d of %d docs in %s (%.0f docs/s), %d retried, %d failed",
+		s.imported, s.read, s.elapsed.Round(time.Millisecond), rate, s.retried, s.failed)
+	if rejects != nil && rejects.written() != 0 {
+		msg += ", rejected to " + rejects.path
+	}
+	return msg
+}
+
+func (im *Importer) run(ctx context.Context, reader itemReader) (*importStats, error) {
+	start := time.Now()
+	stats := new(importStats)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		aborted   error
+		abortOnce sync.Once
+	)
+	// as many batches in flight as workers, so that memory does not grow with the file
+	batches := make(chan []*bulkItem, im.workers)
+	var wg sync.WaitGroup
+	for w := 0; w < im.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if ctx.Err() != nil {
+					// drain the batches of an aborted import
+					continue
+				}
+				if err := im.send(ctx, batch, stats); err != nil {
+					abortOnce.Do(func() {
+						aborted = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	var (
+		batch []*bulkItem
+		size  int
+		err   error
+	)
+	for ctx.Err() == nil {
+		item, rerr := reader.next()
+		if rerr == io.EOF {
+			break
+		}
+		var bad *badLineError
+		if errors.As(rerr, &bad) {
+			atomic.AddInt64(&stats.read, 1)
+			atomic.AddInt64(&stats.failed, 1)
+			im.rejects.write(0, &es.ErrorCause{Type: "bad_line", Reason: bad.Error()}, nil, json.RawMessage(mustMarshal(bad.raw)))
+			continue
+		}
+		if rerr != nil {
+			err = errors.Wrap(rerr, "failed to read import file")
+			break
+		}
+		atomic.AddInt64(&stats.read, 1)
+		if len(batch) != 0 && (len(batch) == im.batchDocs || size+item.size() > im.batchBytes) {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+			}
+			batch, size = nil, 0
+		}
+		batch = append(batch, item)
+		size += item.size()
+	}
+	if len(batch) != 0 && ctx.Err() == nil {
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+	stats.elapsed = time.Since(start)
+	if aborted != nil && err == nil {
+		err = errors.Wrap(aborted, "import aborted")
+	}
+	return stats, err
+}
+
+// send bulks the batch, retrying the items rejected with 429 and rejecting the
+// other failures of items or of es. An error means no batch can get through,
+// like a read only profile, a 401 or 403 or a refused connection.
+func (im *Importer) send(ctx context.Context, batch []*bulkItem, stats *importStats) error {
+	pending := batch
+	for attempt := 0; ; attempt++ {
+		results, err := im.bulk(ctx, pending)
+		var retry []*bulkItem
+		switch {
+		case err != nil && responseStatus(err) < http.StatusInternalServerError && !isTooManyRequests(err):
+			return err
+		case err != nil && !isTooManyRequests(err):
+			cause := &es.ErrorCause{Type: "request_failed", Reason: err.Error()}
+			var re *es.ResponseError
+			if errors.As(err, &re) && re.Type != "" {
+				cause = &re.ErrorCause
+			}
+			for _, it := range pending {
+				im.reject(stats, responseStatus(err), cause, it)
+			}
+			return nil
+		case err != nil:
+			retry = pending
+		case results == nil:
+			// --dry-run printed the batch
+			return nil
+		default:
+			for k, r := range results {
+				switch {
+				case r.Status == http.StatusTooManyRequests:
+					retry = append(retry, pending[k])
+				case r.Error != nil:
+					im.reject(stats, r.Status, r.Error, pending[k])
+				default:
+					atomic.AddInt64(&stats.imported, 1)
+				}
+			}
+		}
+		if len(retry) == 0 {
+			return nil
+		}
+		if attempt == im.maxRetries {
+			cause := &es.ErrorCause{Type: "es_rejected_execution_exception", Reason: fmt.Sprintf("still rejected with 429 after %d retries", im.maxRetries)}
+			for _, it := range retry {
+				im.reject(stats, http.StatusTooManyRequests, cause, it)
+			}
+			return nil
+		}
+		atomic.AddInt64(&stats.retried, int64(len(retry)))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(bulkBackoff(attempt)):
+		}
+		pending = retry
+	}
+}
+
+func (im *Importer) reject(stats *importStats, status int, cause *es.ErrorCause, it *bulkItem) {
+	atomic.AddInt64(&stats.failed, 1)
+	im.rejects.write(status, cause, it.action, it.source)
+}
+
+// bulk sends items and returns the result of each, nil for a dry run.
+func (im *Importer) bulk(ctx context.Context, items []*bulkItem) ([]bulkItemResult, error) {
+	body := new(bytes.Buffer)
+	for _, it := range items {
+		body.Write(it.action)
+		body.WriteByte('\n')
+		if it.source != nil {
+			body.Write(it.source)
+			body.WriteByte('\n')
+		}
+	}
+	opts := []func(*esapi.BulkRequest){im.client.Bulk.WithContext(ctx)}
+	if im.index != "" {
+		opts = append(opts, im.client.Bulk.WithIndex(im.index))
+	}
+	if im.pipeline != "" {
+		opts = append(opts, im.client.Bulk.WithPipeline(im.pipeline))
+	}
+	res, err := im.client.Bulk(body, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := es.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	if isDryRun(res.Header) {
+		return nil, nil
+	}
+	var parsed bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse bulk response")
+	}
+	if len(parsed.Items) != len(items) {
+		return nil, errors.Errorf("bulk response has %d items for %d sent", len(parsed.Items), len(items))
+	}
+	return parsed.results(), nil
+}
+
+func isTooManyRequests(err error) bool {
+	return responseStatus(err) == http.StatusTooManyRequests
+}
+
+func responseStatus(err error) int {
+	var re *es.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode
+	}
+	return 0
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// rejectWriter writes failed items as json lines, creating its file on the first one.
+type rejectWriter struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+	buf  *bufio.Writer
+	n    int64
+	err  error
+}
+
+type rejectedItem struct {
+	Status int             `json:"status,omitempty"`
+	Error  *es.ErrorCause  `json:"error"`
+	Action json.RawMessage `json:"action,omitempty"`
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+func (w *rejectWriter) write(status int, cause *es.ErrorCause, action, source json.RawMessage) {
+	line, _ := json.Marshal(rejectedItem{Status: status, Error: cause, Action: action, Source: source})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.n++
+	if w.err != nil {
+		return
+	}
+	if w.f == nil {
+		if w.f, w.err = os.Create(w.path); w.err != nil {
+			return
+		}
+		w.buf = bufio.NewWriter(w.f)
+	}
+	_, w.err = w.buf.Write(append(line, '\n'))
+}
+
+func (w *rejectWriter) written() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+func (w *rejectWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return w.err
+	}
+	if err := w.buf.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.f = nil
+	return w.err
+}
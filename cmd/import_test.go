@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readItems(t *testing.T, r itemReader) (items []string, bad []string) {
+	for {
+		item, err := r.next()
+		if err == io.EOF {
+			return
+		}
+		if e, ok := err.(*badLineError); ok {
+			bad = append(bad, e.Error())
+			continue
+		}
+		require.NoError(t, err)
+		items = append(items, string(item.action)+" "+string(item.source))
+	}
+}
+
+func TestItemReaders(t *testing.T) {
+	testCases := []struct {
+		name      string
+		format    string
+		keepIndex bool
+		op        string
+		in        string
+		items     []string
+		bad       []string
+	}{
+		{
+			name:   "ndjson",
+			format: importNDJSON,
+			op:     "index",
+			in:     "{\"name\":\"a\"}\n\n{\"_index\":\"old\",\"_id\":\"2\",\"_source\":{\"name\":\"b\"}}\n{bad\n{\"name\":\"c\"}",
+			items:  []string{`{"index":{}} {"name":"a"}`, `{"index":{"_id":"2"}} {"name":"b"}`, `{"index":{}} {"name":"c"}`},
+			bad:    []string{"bad line 4: invalid character 'b' looking for beginning of object key string"},
+		},
+		{
+			name:      "ndjson keeping the exported index",
+			format:    importNDJSON,
+			keepIndex: true,
+			op:        "create",
+			in:        `{"_index":"old","_id":"2","_source":{"name":"b"}}`,
+			items:     []string{`{"create":{"_id":"2","_index":"old"}} {"name":"b"}`},
+		},
+		{
+			name:   "bulk",
+			format: importBulk,
+			in:     "{\"index\":{\"_id\":\"1\"}}\n{\"name\":\"a\"}\n{\"delete\":{\"_id\":\"2\"}}\n{\"update\":{\"_id\":\"3\"}}\n{\"doc\":{\"name\":\"c\"}}\n{\"index\":{},\"create\":{}}\n",
+			items:  []string{`{"index":{"_id":"1"}} {"name":"a"}`, `{"delete":{"_id":"2"}} `, `{"update":{"_id":"3"}} {"doc":{"name":"c"}}`},
+			bad:    []string{"bad line 6: want one action per action line"},
+		},
+		{
+			name:   "csv",
+			format: importCSV,
+			op:     "index",
+			in:     "name,age\nnoah,3\nblackbean\nbulldog,5\n",
+			items:  []string{`{"index":{}} {"age":"3","name":"noah"}`, `{"index":{}} {"age":"5","name":"bulldog"}`},
+			bad:    []string{"bad line 3: record on line 3: wrong number of fields"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newItemReader(strings.NewReader(tc.in), tc.format, tc.keepIndex, tc.op)
+			require.NoError(t, err)
+			items, bad := readItems(t, r)
+			require.Equal(t, tc.items, items)
+			require.Equal(t, tc.bad, bad)
+		})
+	}
+	_, err := newItemReader(strings.NewReader(""), "xml", false, "index")
+	require.EqualError(t, err, `bad --format "xml", one of ndjson, bulk, csv`)
+
+	r, err := newItemReader(strings.NewReader("{\"_index\":\"old\",\"_source\":{}}\n{\"name\":\"a\"}\n"), importNDJSON, true, "index")
+	require.NoError(t, err)
+	_, err = r.next()
+	require.NoError(t, err)
+	_, err = r.next()
+	require.EqualError(t, err, "line 2 has no _index, give the [index] to import to")
+}
+
+func TestImportFormat(t *testing.T) {
+	require.Equal(t, importCSV, importFormat("people.csv.gz"))
+	require.Equal(t, importBulk, importFormat("requests.bulk"))
+	require.Equal(t, importNDJSON, importFormat("noah.ndjson"))
+	require.Equal(t, importNDJSON, importFormat("-"))
+	require.Equal(t, "noah.ndjson.rejected.ndjson", defaultRejectFile("noah.ndjson.gz"))
+	require.Equal(t, "rejected.ndjson", defaultRejectFile("-"))
+}
+
+// bulkTransport answers bulk requests, rejecting the doc named "busy" with 429
+// on its first attempt and the doc named "bad" for good, or every request with
+// status when set.
+type bulkTransport struct {
+	mu       sync.Mutex
+	requests int
+	busy     int
+	status   int
+}
+
+func (t *bulkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests++
+	if t.status != 0 {
+		body := fmt.Sprintf(`{"error":{"type":"security_exception","reason":"unauthorized"},"status":%d}`, t.status)
+		return &http.Response{StatusCode: t.status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	var items []string
+	lines := bufio.NewScanner(req.Body)
+	for lines.Scan() {
+		if !lines.Scan() {
+			break
+		}
+		var doc map[string]string
+		_ = json.Unmarshal(lines.Bytes(), &doc)
+		switch doc["name"] {
+		case "busy":
+			t.busy++
+			if t.busy == 1 {
+				items = append(items, `{"index":{"_index":"noah","status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+				continue
+			}
+		case "bad":
+			items = append(items, `{"index":{"_index":"noah","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+			continue
+		}
+		items = append(items, `{"index":{"_index":"noah","status":201}}`)
+	}
+	body := fmt.Sprintf(`{"errors":true,"items":[%s]}`, strings.Join(items, ","))
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestIndexImport(t *testing.T) {
+	defer func(backoff func(int) time.Duration) { bulkBackoff = backoff }(bulkBackoff)
+	bulkBackoff = func(int) time.Duration { return 0 }
+	dir := t.TempDir()
+	file := filepath.Join(dir, "noah.ndjson")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"name":"a"}
+{"name":"busy"}
+{"name":"bad"}
+not json
+{"name":"b"}
+`), 0600))
+	mock := &bulkTransport{}
+	o, err := executeCommand("index import noah -f "+file+" --batch_size 2 --workers 2", mock)
+	require.EqualError(t, err, "2 docs failed, see "+file+".rejected.ndjson")
+	require.Contains(t, o, "imported 3 of 5 docs in ")
+	require.Contains(t, o, "1 retried, 2 failed, rejected to "+file+".rejected.ndjson")
+	require.Equal(t, 3, mock.requests)
+
+	rejected, err := ioutil.ReadFile(file + ".rejected.ndjson")
+	require.NoError(t, err)
+	require.Contains(t, string(rejected), `{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"},"action":{"index":{}},"source":{"name":"bad"}}`)
+	require.Contains(t, string(rejected), `{"error":{"type":"bad_line","reason":"bad line 4: invalid character 'o' in literal null (expecting 'u')"},"source":"not json"}`)
+}
+
+func TestIndexImportAborted(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "noah.ndjson")
+	require.NoError(t, ioutil.WriteFile(file, []byte(strings.Repeat(`{"name":"a"}`+"\n", 10)), 0600))
+	mock := &bulkTransport{status: http.StatusForbidden}
+	o, err := executeCommand("index import noah -f "+file+" --batch_size 1 --workers 1", mock)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "import aborted")
+	require.Contains(t, o, "imported 0 of ")
+	require.Equal(t, 1, mock.requests)
+	_, err = os.Stat(file + ".rejected.ndjson")
+	require.True(t, os.IsNotExist(err), "nothing rejected when aborted")
+}
+
+func TestIndexImportWithoutIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "people.csv")
+	require.NoError(t, ioutil.WriteFile(file, []byte("name\nnoah\n"), 0600))
+	mock := &bulkTransport{}
+	_, err := executeCommand("index import -f "+file, mock)
+	require.EqualError(t, err, "csv rows have no _index, give the [index] to import to")
+	require.Equal(t, 0, mock.requests)
+}
+
+func TestRejectWriter(t *testing.T) {
+	rejects := &rejectWriter{path: filepath.Join(t.TempDir(), "rejected.ndjson")}
+	require.NoError(t, rejects.Close())
+	_, err := ioutil.ReadFile(rejects.path)
+	require.True(t, os.IsNotExist(err), "no reject, no file")
+
+	im := &Importer{rejects: rejects}
+	stats := new(importStats)
+	im.reject(stats, http.StatusTooManyRequests, nil, &bulkItem{action: []byte(`{"index":{}}`), source: []byte(`{}`)})
+	require.NoError(t, rejects.Close())
+	require.Equal(t, int64(1), stats.failed)
+	require.Equal(t, int64(1), rejects.written())
+	rejected, err := ioutil.ReadFile(rejects.path)
+	require.NoError(t, err)
+	require.Equal(t, `{"status":429,"error":null,"action":{"index":{}},"source":{}}`+"\n", string(rejected))
+}
+
+func TestImportSummary(t *testing.T) {
+	s := &importStats{read: 10, imported: 8, failed: 2, retried: 3, elapsed: 2 * time.Second}
+	require.Equal(t, "imported 8 of 10 docs in 2s (4 docs/s), 3 retried, 2 failed", s.summary(&rejectWriter{path: "rejected.ndjson"}))
+}
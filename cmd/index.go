@@ -9,11 +9,13 @@ import (
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/toughnoah/blackbean/pkg/es"
 	"io"
 	"io/ioutil"
 	"log"
 	"strings"
+	"time"
 )
 
 func index(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
@@ -30,6 +32,8 @@ func index(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	command.AddCommand(writeIndex(cli, out))
 	command.AddCommand(bulk(cli, out))
 	command.AddCommand(mSearch(cli, out))
+	command.AddCommand(exportIndex(cli, out))
+	command.AddCommand(importIndex(cli, out))
 	return command
 }
 
@@ -47,10 +51,10 @@ func getIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			res, err := i.getIndices(args[0])
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, indexLayout)
 		},
 	}
 	return command
@@ -71,10 +75,10 @@ func searchIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			res, err := i.searchIndex(args[0], req)
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, nil)
 		},
 	}
 	es.AddRequestBodyFlag(command, req)
@@ -91,10 +95,10 @@ func createIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 		ValidArgsFunction: noCompletions,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			res, err := i.createIndex(args[0], req)
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, nil)
 		},
 	}
 	es.AddRequestBodyFlag(command, req)
@@ -103,6 +107,7 @@ func createIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 
 func deleteIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	i := Indices{client: cli}
+	var yes bool
 	var command = &cobra.Command{
 		Use:   "delete [index]",
 		Short: "delete index from command",
@@ -114,13 +119,17 @@ func deleteIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			return i.getAllIndices(), cobra.ShellCompDirectiveNoFileComp
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirmDestructive(out, yes, "delete indices", func() []string { return i.describeIndices(args[0]) }); err != nil {
+				return err
+			}
 			res, err := i.deleteIndices(args[0])
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, nil)
 		},
 	}
+	addYesFlag(command, &yes)
 	return command
 }
 
@@ -128,24 +137,54 @@ func reindex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	req := new(es.RequestBody)
 	i := Indices{client: cli}
 	var (
+		r       = Reindex{Client: cli}
+		opts    reindexOptions
+		follow  bool
 		command = &cobra.Command{
 			Use:   "reindex [index] [newIndex]",
 			Short: "do reindex",
-			Long:  "do reindex ... wordless",
+			Long:  "do reindex, with --follow showing its progress until done, Ctrl-C stops following but not the reindex ... wordless",
 			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 				return i.getAllIndices(), cobra.ShellCompDirectiveNoFileComp
 			},
 			Args: cobra.ExactArgs(2),
 			RunE: func(cmd *cobra.Command, args []string) error {
-				res, err := i.reIndex(args[0], args[1], req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if follow && r.poll < minReindexPoll {
+					return errors.Errorf("--poll_interval must be at least %s", minReindexPoll)
 				}
-				return err
+				res, err := i.reIndex(args[0], args[1], req, opts)
+				if err != nil {
+					return err
+				}
+				if !follow {
+					return printResponse(out, res, nil)
+				}
+				defer res.Body.Close()
+				if err := es.CheckResponse(res); err != nil {
+					return err
+				}
+				if isDryRun(res.Header) {
+					return nil
+				}
+				var started struct {
+					Task string `json:"task"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&started); err != nil || started.Task == "" {
+					return errors.New("failed to get the task of the reindex")
+				}
+				return r.follow(context.Background(), out, started.Task)
 			},
 		}
 	)
 	es.AddRequestBodyFlag(command, req)
+	f := command.Flags()
+	f.BoolVar(&follow, "follow", false, "poll the reindex task, showing created, updated and total docs, the rate and the time left, then the failures.")
+	f.DurationVar(&r.poll, "poll_interval", 2*time.Second, "time between two polls of --follow, at least 100ms.")
+	f.SetNormalizeFunc(flagAliases)
+	f.IntVar(&opts.requestsPerSecond, "requests_per_second", 0, "throttle of the reindex in sub-requests per second, -1 for no limit, the es default.")
+	f.StringVar(&opts.slices, "slices", "", "number of slices the reindex runs in parallel, or auto to let es pick.")
+	command.AddCommand(rethrottleReindex(cli, out))
+	command.AddCommand(cancelReindex(cli, out))
 	return command
 }
 
@@ -168,10 +207,10 @@ func writeIndex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				return es.NoRawRequestFlagError()
 			}
 			res, err := i.writeIndex(args[0], req)
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, nil)
 		},
 	}
 	es.AddRequestBodyFlag(command, req)
@@ -182,9 +221,11 @@ func bulk(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		i            = Indices{client: cli}
 		requireAlias bool
+		failOnError  bool
 		pipeline     string
 		rawFile      string
 		data         string
+		filename     string
 		command      = &cobra.Command{
 			Use:   "bulk",
 			Short: "send bulk request",
@@ -198,29 +239,43 @@ func bulk(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				i.rawFile = rawFile
-				fmt.Println(i.rawFile)
+				i.data = data
+				i.filename = filename
 				if len(args) != 0 {
 					i.index = args[0]
 				}
-				if es.GetFlagValue(cmd, "data") == es.EmptyData && es.GetFlagValue(cmd, "raw_file") == es.EmptyFile {
-					return errors.New("one of --data and --raw_file should be specified")
+				if err := checkNDJSONFlags(cmd); err != nil {
+					return err
 				}
 				res, err := i.bulk(requireAlias, pipeline)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printBulkResponse(out, res, failOnError)
 			},
 		}
 	)
 	f := command.Flags()
 	f.StringVar(&pipeline, "pipeline", "", "ID of the pipeline to use to preprocess incoming documents.")
 	f.BoolVar(&requireAlias, "require_alias", false, "if true, the request’s actions must target an index alias.")
+	f.SetNormalizeFunc(flagAliases)
+	f.BoolVar(&failOnError, "fail_on_error", true, "exit non zero when any item of the bulk request failed.")
 	f.StringVar(&rawFile, "raw_file", es.EmptyFile, "the path to raw file with request body")
 	f.StringVarP(&data, "data", "d", es.EmptyData, "the path to raw file with request body")
+	f.StringVarP(&filename, "filename", "f", es.EmptyFile, "yaml or json file of entries, each an index, create, update or delete action and its doc.")
 	return command
 }
 
+// flagAliases lets the kebab case spellings of the index flags, like
+// --requests-per-second, stand for their snake case names.
+func flagAliases(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	switch name {
+	case "requests-per-second", "poll-interval", "fail-on-error":
+		name = strings.ReplaceAll(name, "-", "_")
+	}
+	return pflag.NormalizedName(name)
+}
+
 func mSearch(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		i                          = Indices{client: cli}
@@ -228,6 +283,7 @@ func mSearch(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 		maxConcurrentShardRequests int
 		rawFile                    string
 		data                       string
+		filename                   string
 		command                    = &cobra.Command{
 			Use:   "msearch",
 			Short: "send msearch request",
@@ -241,17 +297,19 @@ func mSearch(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				i.rawFile = rawFile
+				i.data = data
+				i.filename = filename
 				if len(args) != 0 {
 					i.index = args[0]
 				}
-				if es.GetFlagValue(cmd, "data") == es.EmptyData && es.GetFlagValue(cmd, "raw_file") == es.EmptyFile {
-					return errors.New("one of --data and --raw_file should be specified")
+				if err := checkNDJSONFlags(cmd); err != nil {
+					return err
 				}
 				res, err := i.msearch(maxConcurrentSearches, maxConcurrentShardRequests)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -260,14 +318,34 @@ func mSearch(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	f.IntVar(&maxConcurrentShardRequests, "max_concurrent_shard_requests", 0, "if true, the request’s actions must target an index alias.")
 	f.StringVar(&rawFile, "raw_file", es.EmptyFile, "the path to raw file with request body")
 	f.StringVarP(&data, "data", "d", es.EmptyData, "the path to raw file with request body")
+	f.StringVarP(&filename, "filename", "f", es.EmptyFile, "yaml or json file of entries, each a header and a search body.")
 	return command
 }
 
+// checkNDJSONFlags wants exactly one body of bulk and msearch.
+func checkNDJSONFlags(cmd *cobra.Command) error {
+	set := 0
+	for _, unset := range []bool{
+		es.GetFlagValue(cmd, "data") == es.EmptyData,
+		es.GetFlagValue(cmd, "raw_file") == es.EmptyFile,
+		es.GetFlagValue(cmd, "filename") == es.EmptyFile,
+	} {
+		if !unset {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("one of --data, --raw_file and --filename should be specified")
+	}
+	return nil
+}
+
 type Indices struct {
-	client  *elasticsearch.Client
-	index   string
-	rawFile string
-	data    string
+	client   *elasticsearch.Client
+	index    string
+	rawFile  string
+	data     string
+	filename string
 }
 
 func (i *Indices) getAllIndices() []string {
@@ -294,6 +372,29 @@ func (i *Indices) getIndices(indices string) (res *esapi.Response, err error) {
 	return i.client.Indices.Get(splitWords(indices), i.client.Indices.Get.WithPretty())
 }
 
+// describeIndices expands the indices to delete, with their doc count and size.
+func (i *Indices) describeIndices(indices string) []string {
+	res, err := i.client.Cat.Indices(
+		i.client.Cat.Indices.WithIndex(splitWords(indices)...),
+		i.client.Cat.Indices.WithH("index", "docs.count", "store.size"),
+		i.client.Cat.Indices.WithS("index"),
+		i.client.Cat.Indices.WithExpandWildcards("all"),
+		i.client.Cat.Indices.WithFormat("json"))
+	if err != nil {
+		return splitWords(indices)
+	}
+	defer res.Body.Close()
+	var rows []map[string]string
+	if res.IsError() || json.NewDecoder(res.Body).Decode(&rows) != nil || len(rows) == 0 {
+		return splitWords(indices)
+	}
+	var described []string
+	for _, row := range rows {
+		described = append(described, fmt.Sprintf("%s (%s docs, %s)", row["index"], row["docs.count"], row["store.size"]))
+	}
+	return described
+}
+
 func (i *Indices) deleteIndices(indices string) (res *esapi.Response, err error) {
 	return i.client.Indices.Delete(splitWords(indices), i.client.Indices.Delete.WithIgnoreUnavailable(true))
 }
@@ -326,7 +427,7 @@ func (i *Indices) createIndex(index string, req *es.RequestBody) (res *esapi.Res
 	return i.client.Indices.Create(index, i.client.Indices.Create.WithBody(bytes.NewReader(body)))
 }
 
-func (i *Indices) reIndex(source, dest string, req *es.RequestBody) (res *esapi.Response, err error) {
+func (i *Indices) reIndex(source, dest string, req *es.RequestBody, opts reindexOptions) (res *esapi.Response, err error) {
 	body, err := es.GetRawRequestBody(req)
 	if err != nil {
 		return nil, err
@@ -334,7 +435,7 @@ func (i *Indices) reIndex(source, dest string, req *es.RequestBody) (res *esapi.
 	if body == nil {
 		body = []byte(fmt.Sprintf(`{"source":{"index":"%s"}, "dest":{"index": "%s"}}`, source, dest))
 	}
-	return i.doReindex(bytes.NewReader(body))
+	return i.doReindex(bytes.NewReader(body), opts)
 }
 
 func (i *Indices) writeIndex(index string, req *es.RequestBody) (res *esapi.Response, err error) {
@@ -358,15 +459,11 @@ func (i *Indices) bulk(requireAlias bool, pipeline string) (res *esapi.Response,
 		bulkRequest = append(bulkRequest, i.client.Bulk.WithRequireAlias(true))
 	}
 	bulkRequest = append(bulkRequest, i.client.Bulk.WithPretty())
-	if i.rawFile != "" {
-		body, err := i.readFromRawFile()
-		if err != nil {
-			return nil, err
-		}
-		return i.client.Bulk(bytes.NewReader(body),
-			bulkRequest...)
+	body, err := i.ndjsonBody(bulkNDJSON)
+	if err != nil {
+		return nil, err
 	}
-	return i.client.Bulk(strings.NewReader(i.data),
+	return i.client.Bulk(bytes.NewReader(body),
 		bulkRequest...)
 }
 
@@ -382,21 +479,52 @@ func (i *Indices) msearch(maxConcurrentSearches, maxConcurrentShardRequests int)
 		mSearchRequest = append(mSearchRequest, i.client.Msearch.WithMaxConcurrentShardRequests(maxConcurrentShardRequests))
 	}
 	mSearchRequest = append(mSearchRequest, i.client.Msearch.WithPretty())
-	if i.rawFile != "" {
-		body, err := i.readFromRawFile()
-		if err != nil {
+	body, err := i.ndjsonBody(msearchNDJSON)
+	if err != nil {
+		return nil, err
+	}
+	return i.client.Msearch(bytes.NewReader(body),
+		mSearchRequest...)
+}
+
+// ndjsonBody is the body of --filename serialized by toNDJSON, of --raw_file or of --data,
+// ending with a newline.
+func (i *Indices) ndjsonBody(toNDJSON func([]json.RawMessage) ([]byte, error)) ([]byte, error) {
+	var (
+		body []byte
+		err  error
+	)
+	switch {
+	case i.filename != "":
+		var entries []json.RawMessage
+		if entries, err = es.DecodeAllFromFile(i.filename); err != nil {
 			return nil, err
 		}
-		return i.client.Msearch(bytes.NewReader(body),
-			mSearchRequest...)
+		body, err = toNDJSON(entries)
+	case i.rawFile != "":
+		body, err = i.readFromRawFile()
+	default:
+		body = []byte(i.data)
 	}
-	return i.client.Msearch(strings.NewReader(i.data),
-		mSearchRequest...)
+	if err != nil {
+		return nil, err
+	}
+	return withTrailingNewline(body), nil
 }
 
-func (i *Indices) doReindex(body io.Reader) (res *esapi.Response, err error) {
-	res, err = i.client.Reindex(body, i.client.Reindex.WithWaitForCompletion(false))
-	return
+func (i *Indices) doReindex(body io.Reader, opts reindexOptions) (res *esapi.Response, err error) {
+	reindexRequest := []func(*esapi.ReindexRequest){i.client.Reindex.WithWaitForCompletion(false)}
+	if opts.requestsPerSecond != 0 {
+		reindexRequest = append(reindexRequest, i.client.Reindex.WithRequestsPerSecond(opts.requestsPerSecond))
+	}
+	slices, err := opts.slicesValue()
+	if err != nil {
+		return nil, err
+	}
+	if slices != "" {
+		reindexRequest = append(reindexRequest, i.client.Reindex.WithSlices(slices))
+	}
+	return i.client.Reindex(body, reindexRequest...)
 }
 
 func (i *Indices) readFromRawFile() ([]byte, error) {
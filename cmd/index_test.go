@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/es"
 	"github.com/toughnoah/blackbean/pkg/fake"
 	"testing"
 )
@@ -40,6 +41,40 @@ func TestSearchIndex(t *testing.T) {
 	}
 }
 
+func TestSearchIndexOutput(t *testing.T) {
+	mock := &fake.MockEsResponse{
+		ResponseString: `{"hits":{"hits":[{"_id":"1"},{"_id":"2"}]}}`,
+	}
+	testCases := []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "jsonpath",
+			cmd:  `index search test-* -o 'jsonpath={.hits.hits[*]._id}'`,
+			want: "1 2",
+		},
+		{
+			name: "go-template",
+			cmd:  `index search test-* -o 'go-template={{len .hits.hits}}'`,
+			want: "2",
+		},
+		{
+			name: "template file",
+			cmd:  "index search test-* --template-file ../pkg/testdata/ids.tmpl",
+			want: "1\n2\n",
+		},
+	}
+	for _, tc := range testCases {
+		out, err := executeCommand(tc.cmd, mock)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.want, out, tc.name)
+	}
+	_, err := executeCommand("index search test-* -o json --template-file ../pkg/testdata/ids.tmpl", mock)
+	require.EqualError(t, err, "--template-file needs -o jsonpath or -o go-template")
+}
+
 func TestCreateIndex(t *testing.T) {
 	mock := &fake.MockEsResponse{
 		ResponseString: `{"acknowledge":true}`,
@@ -54,6 +89,14 @@ func TestDeleteIndex(t *testing.T) {
 	}
 	_, err := executeCommand(`index delete test-* `, mock)
 	require.NoError(t, err)
+
+	notFound := &fake.MockEsResponse{
+		ResponseString: `{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index [nosuch]"}],"type":"index_not_found_exception","reason":"no such index [nosuch]"},"status":404}`,
+		StatusCode:     404,
+	}
+	_, err = executeCommand(`index delete nosuch`, notFound)
+	require.EqualError(t, err, "[404 Not Found] index_not_found_exception: no such index [nosuch]")
+	require.Equal(t, es.ExitCodeClientError, es.ExitCode(err))
 }
 
 func TestReIndex(t *testing.T) {
@@ -84,8 +127,12 @@ func TestBulk(t *testing.T) {
 	require.NoError(t, err)
 	_, err = executeCommand("index bulk --raw_file ../pkg/testdata/bulk.json --pipeline test --require_alias true", mock)
 	require.NoError(t, err)
+	_, err = executeCommand("index bulk -f ../pkg/testdata/bulk.yaml", mock)
+	require.NoError(t, err)
 	_, err = executeCommand("index bulk", mock)
 	require.Error(t, err)
+	_, err = executeCommand("index bulk -f ../pkg/testdata/bulk.yaml --raw_file ../pkg/testdata/bulk.json", mock)
+	require.EqualError(t, err, "one of --data, --raw_file and --filename should be specified")
 }
 
 func TestMsearch(t *testing.T) {
@@ -94,6 +141,8 @@ func TestMsearch(t *testing.T) {
 	}
 	_, err := executeCommand(`index msearch -d "{"test":"abc"}"`, mock)
 	require.NoError(t, err)
+	_, err = executeCommand("index msearch -f ../pkg/testdata/msearch.json", mock)
+	require.NoError(t, err)
 	_, err = executeCommand("index msearch --raw_file ../pkg/testdata/bulk.json --max_concurrent_searches 1 --max_concurrent_shard_requests 1", mock)
 	require.NoError(t, err)
 	_, err = executeCommand("index msearch", mock)
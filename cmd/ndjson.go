@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+var (
+	bulkActions = []string{"index", "create", "update", "delete"}
+	// updateKeys make the source line of an update, a bare doc being wrapped in it
+	updateKeys = []string{"doc", "upsert", "script", "doc_as_upsert", "scripted_upsert", "detect_noop"}
+)
+
+// bulkNDJSON serializes entries like {index: {_id: "1"}, doc: {...}} to a bulk body.
+// An update takes doc, upsert or script, a delete no doc at all.
+func bulkNDJSON(entries []json.RawMessage) ([]byte, error) {
+	body := new(bytes.Buffer)
+	for n, raw := range entries {
+		var entry map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, errors.Wrapf(err, "bad bulk entry %d", n+1)
+		}
+		var actions []string
+		for _, a := range bulkActions {
+			if _, ok := entry[a]; ok {
+				actions = append(actions, a)
+			}
+		}
+		if len(actions) != 1 {
+			return nil, errors.Errorf("bad bulk entry %d, want exactly one of %s", n+1, strings.Join(bulkActions, ", "))
+		}
+		action := actions[0]
+		meta := entry[action]
+		delete(entry, action)
+		if isNull(meta) {
+			meta = json.RawMessage(`{}`)
+		}
+		body.WriteString(`{"` + action + `":`)
+		if err := json.Compact(body, meta); err != nil {
+			return nil, errors.Wrapf(err, "bad bulk entry %d", n+1)
+		}
+		body.WriteString("}\n")
+
+		var source json.RawMessage
+		switch action {
+		case "delete":
+			if len(entry) != 0 {
+				return nil, errors.Errorf("bad bulk entry %d, delete takes no %s", n+1, keys(entry))
+			}
+			continue
+		case "update":
+			update := map[string]json.RawMessage{}
+			for _, k := range updateKeys {
+				if v, ok := entry[k]; ok {
+					update[k] = v
+					delete(entry, k)
+				}
+			}
+			if len(update) == 0 {
+				return nil, errors.Errorf("bad bulk entry %d, update needs one of doc, upsert, script", n+1)
+			}
+			source, _ = json.Marshal(update)
+		default:
+			doc, ok := entry["doc"]
+			if !ok || isNull(doc) {
+				return nil, errors.Errorf("bad bulk entry %d, %s needs a doc", n+1, action)
+			}
+			delete(entry, "doc")
+			source = doc
+		}
+		if len(entry) != 0 {
+			return nil, errors.Errorf("bad bulk entry %d, unknown %s", n+1, keys(entry))
+		}
+		if err := json.Compact(body, source); err != nil {
+			return nil, errors.Wrapf(err, "bad bulk entry %d", n+1)
+		}
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
+
+// msearchNDJSON serializes entries like {header: {index: noah}, body: {query: ...}} to a msearch body.
+func msearchNDJSON(entries []json.RawMessage) ([]byte, error) {
+	body := new(bytes.Buffer)
+	for n, raw := range entries {
+		var entry struct {
+			Header json.RawMessage `json:"header"`
+			Body   json.RawMessage `json:"body"`
+		}
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&entry); err != nil {
+			return nil, errors.Wrapf(err, "bad msearch entry %d", n+1)
+		}
+		if isNull(entry.Body) {
+			return nil, errors.Errorf("bad msearch entry %d, needs a body", n+1)
+		}
+		if isNull(entry.Header) {
+			entry.Header = json.RawMessage(`{}`)
+		}
+		for _, line := range []json.RawMessage{entry.Header, entry.Body} {
+			if err := json.Compact(body, line); err != nil {
+				return nil, errors.Wrapf(err, "bad msearch entry %d", n+1)
+			}
+			body.WriteByte('\n')
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// withTrailingNewline ends an ndjson body with the newline es insists on.
+func withTrailingNewline(body []byte) []byte {
+	body = bytes.TrimRight(body, " \t\r\n")
+	if len(body) == 0 {
+		return body
+	}
+	return append(body, '\n')
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+func keys(m map[string]json.RawMessage) string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return strings.Join(ks, ", ")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/es"
+)
+
+func TestBulkNDJSON(t *testing.T) {
+	entries, err := es.DecodeAllFromFile("../pkg/testdata/bulk.yaml")
+	require.NoError(t, err)
+	body, err := bulkNDJSON(entries)
+	require.NoError(t, err)
+	require.Equal(t, `{"index":{"_id":"1","_index":"noah"}}
+{"age":3,"name":"blackbean"}
+{"delete":{"_id":"2","_index":"noah"}}
+{"create":{"_id":"3","_index":"noah"}}
+{"name":"bulldog"}
+{"update":{"_id":"1","_index":"noah"}}
+{"doc":{"age":4},"doc_as_upsert":true}
+`, string(body))
+
+	testCases := []struct {
+		entry string
+		err   string
+	}{
+		{entry: `{"doc":{}}`, err: "bad bulk entry 1, want exactly one of index, create, update, delete"},
+		{entry: `{"index":{},"delete":{}}`, err: "bad bulk entry 1, want exactly one of index, create, update, delete"},
+		{entry: `{"index":{}}`, err: "bad bulk entry 1, index needs a doc"},
+		{entry: `{"update":{}}`, err: "bad bulk entry 1, update needs one of doc, upsert, script"},
+		{entry: `{"delete":{},"doc":{}}`, err: "bad bulk entry 1, delete takes no doc"},
+		{entry: `{"create":{},"doc":{},"source":{}}`, err: "bad bulk entry 1, unknown source"},
+		// the wording of json errors depends on the go version
+		{entry: `[]`},
+	}
+	for _, tc := range testCases {
+		_, err := bulkNDJSON([]json.RawMessage{json.RawMessage(tc.entry)})
+		require.Error(t, err, tc.entry)
+		if tc.err != "" {
+			require.EqualError(t, err, tc.err, tc.entry)
+		}
+	}
+	body, err = bulkNDJSON([]json.RawMessage{json.RawMessage("{\"index\": null, \"doc\": {\n  \"a\": 1\n}}")})
+	require.NoError(t, err)
+	require.Equal(t, "{\"index\":{}}\n{\"a\":1}\n", string(body))
+}
+
+func TestMsearchNDJSON(t *testing.T) {
+	entries, err := es.DecodeAllFromFile("../pkg/testdata/msearch.json")
+	require.NoError(t, err)
+	body, err := msearchNDJSON(entries)
+	require.NoError(t, err)
+	require.Equal(t, `{"index":"noah"}
+{"query":{"match_all":{}}}
+{}
+{"query":{"term":{"name":"blackbean"}},"size":1}
+`, string(body))
+
+	_, err = msearchNDJSON([]json.RawMessage{json.RawMessage(`{"header":{}}`)})
+	require.EqualError(t, err, "bad msearch entry 1, needs a body")
+	_, err = msearchNDJSON([]json.RawMessage{json.RawMessage(`{"query":{}}`)})
+	require.EqualError(t, err, `bad msearch entry 1: json: unknown field "query"`)
+}
+
+func TestWithTrailingNewline(t *testing.T) {
+	require.Equal(t, "{}\n{}\n", string(withTrailingNewline([]byte("{}\n{}"))))
+	require.Equal(t, "{}\n{}\n", string(withTrailingNewline([]byte("{}\n{}\n\n"))))
+	require.Empty(t, withTrailingNewline([]byte("\n")))
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/printer"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	indexLayout = &printer.Resource{
+		Columns: []printer.Column{
+			{Header: "ALIASES", Path: "aliases"},
+			{Header: "SHARDS", Path: "settings.index.number_of_shards"},
+			{Header: "REPLICAS", Path: "settings.index.number_of_replicas"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "UUID", Path: "settings.index.uuid"},
+			{Header: "CREATED", Path: "settings.index.creation_date"},
+			{Header: "VERSION", Path: "settings.index.version.created"},
+		},
+	}
+	aliasLayout = &printer.Resource{
+		Columns: []printer.Column{
+			{Header: "ALIASES", Path: "aliases"},
+		},
+	}
+	userLayout = &printer.Resource{
+		Columns: []printer.Column{
+			{Header: "ROLES", Path: "roles"},
+			{Header: "ENABLED", Path: "enabled"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "FULL_NAME", Path: "full_name"},
+			{Header: "EMAIL", Path: "email"},
+		},
+	}
+	roleLayout = &printer.Resource{
+		Columns: []printer.Column{
+			{Header: "CLUSTER", Path: "cluster"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "INDICES", Path: "indices"},
+			{Header: "RUN_AS", Path: "run_as"},
+		},
+	}
+	templateLayout = &printer.Resource{
+		Columns: []printer.Column{
+			{Header: "PATTERNS", Path: "index_patterns"},
+			{Header: "ORDER", Path: "order"},
+			{Header: "VERSION", Path: "version"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "ALIASES", Path: "aliases"},
+		},
+	}
+	taskLayout = &printer.Resource{
+		Items: "tasks",
+		Columns: []printer.Column{
+			{Header: "NODE", Path: "node"},
+			{Header: "ID", Path: "id"},
+			{Header: "ACTION", Path: "action"},
+			{Header: "CANCELLABLE", Path: "cancellable"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "PARENT", Path: "parent_task_id"},
+			{Header: "DESCRIPTION", Path: "description"},
+		},
+	}
+	snapshotLayout = &printer.Resource{
+		Items:   "snapshots",
+		NameKey: "snapshot",
+		Columns: []printer.Column{
+			{Header: "STATE", Path: "state"},
+			{Header: "START", Path: "start_time"},
+			{Header: "END", Path: "end_time"},
+		},
+		WideColumns: []printer.Column{
+			{Header: "INDICES", Path: "indices"},
+			{Header: "SHARDS", Path: "shards.total"},
+			{Header: "FAILED", Path: "shards.failed"},
+			{Header: "UUID", Path: "uuid"},
+		},
+	}
+)
+
+// resolveOutput validates -o/--output and inlines --template-file into it.
+func resolveOutput() error {
+	if templateFile != "" {
+		format := output
+		if format == "" {
+			format = printer.GoTemplate
+		}
+		if format != printer.JSONPath && format != printer.GoTemplate {
+			return errors.Errorf("--template-file needs -o %s or -o %s", printer.JSONPath, printer.GoTemplate)
+		}
+		tmpl, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read template file")
+		}
+		output = format + "=" + string(tmpl)
+	}
+	if output == "" {
+		return nil
+	}
+	return printer.ValidateFormat(output)
+}
+
+// printResponse writes res as asked by -o/--output, the raw response when unset.
+// A non 2xx response is not printed but returned as an *es.ResponseError.
+func printResponse(out io.Writer, res *esapi.Response, layout *printer.Resource) error {
+	if err := es.CheckResponse(res); err != nil {
+		return err
+	}
+	if isDryRun(res.Header) {
+		// the request was printed instead of sent
+		res.Body.Close()
+		return nil
+	}
+	if output == "" {
+		fmt.Fprintln(out, res)
+		return nil
+	}
+	p, err := printer.New(output, layout)
+	if err != nil {
+		return err
+	}
+	return p.PrintResponse(out, res)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	rawMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}
+	// rawPaths are suggested for the path of raw, besides the indices.
+	rawPaths = []string{
+		"/_cat/", "/_cat/health", "/_cat/indices", "/_cat/nodes", "/_cat/shards", "/_cat/allocation",
+		"/_cat/thread_pool", "/_cat/pending_tasks", "/_cat/recovery", "/_cat/segments",
+		"/_cluster/health", "/_cluster/state", "/_cluster/stats", "/_cluster/settings",
+		"/_cluster/allocation/explain", "/_cluster/reroute", "/_cluster/pending_tasks",
+		"/_nodes", "/_nodes/stats", "/_nodes/hot_threads", "/_nodes/usage",
+		"/_tasks", "/_snapshot", "/_template", "/_index_template", "/_component_template",
+		"/_ilm/policy", "/_ilm/status", "/_ingest/pipeline", "/_alias", "/_aliases",
+		"/_security/user", "/_security/role", "/_security/_authenticate", "/_license",
+		"/_search", "/_msearch", "/_bulk", "/_mget", "/_reindex", "/_stats", "/_refresh", "/_flush",
+	}
+)
+
+func raw(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		params  []string
+		headers []string
+		yes     bool
+		req     = &es.RequestBody{}
+		r       = Raw{Client: cli}
+		i       = Indices{client: cli}
+		command = &cobra.Command{
+			Use:   "raw [method] [path]",
+			Short: "send any request to es with the current cluster profile",
+			Long:  "send any request to es with the current cluster profile, for the apis no other command covers ... wordless",
+			Example: `  blackbean raw GET /_nodes/hot_threads
+  blackbean raw PUT /noah/_settings -f body.yaml
+  blackbean raw GET /_cat/indices --param v=true --param s=index -H 'Accept: text/plain'`,
+			Args: cobra.ExactArgs(2),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				switch len(args) {
+				case 0:
+					return rawMethods, cobra.ShellCompDirectiveNoFileComp
+				case 1:
+					return rawPathCompletions(i.getAllIndices()), cobra.ShellCompDirectiveNoFileComp
+				}
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var body []byte
+				if !es.NoRawRequestBodySet(cmd) {
+					var err error
+					if body, err = es.GetRawRequestBody(req); err != nil {
+						return err
+					}
+				}
+				if strings.EqualFold(args[0], http.MethodDelete) {
+					target := func() []string { return []string{http.MethodDelete + " " + args[1]} }
+					if err := confirmDestructive(out, yes, "raw request", target); err != nil {
+						return err
+					}
+				}
+				res, err := r.perform(args[0], args[1], params, headers, body)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	f := es.AddRequestBodyFlag(command, req)
+	f.StringArrayVar(&params, "param", nil, "query string parameter as key=value, may be repeated.")
+	f.StringArrayVarP(&headers, "header", "H", nil, "request header as 'Name: value', may be repeated.")
+	addYesFlag(command, &yes)
+	return command
+}
+
+func rawPathCompletions(indices []string) []string {
+	paths := append([]string{}, rawPaths...)
+	for _, index := range indices {
+		paths = append(paths, "/"+index)
+	}
+	return paths
+}
+
+type Raw struct {
+	Client *elasticsearch.Client
+}
+
+// perform sends the request through the transport of the client, so that the
+// profile, --dry-run, read_only and the audit log apply as to any other command.
+func (r *Raw) perform(method, path string, params, headers []string, body []byte) (*esapi.Response, error) {
+	req, err := newRawRequest(method, path, params, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.Client.Perform(req)
+	if err != nil {
+		return nil, err
+	}
+	return &esapi.Response{StatusCode: res.StatusCode, Body: res.Body, Header: res.Header}, nil
+}
+
+func newRawRequest(method, path string, params, headers []string, body []byte) (*http.Request, error) {
+	method = strings.ToUpper(method)
+	known := false
+	for _, m := range rawMethods {
+		known = known || m == method
+	}
+	if !known {
+		return nil, errors.Errorf("bad method %q, one of %s", method, strings.Join(rawMethods, ", "))
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad path %q", path)
+	}
+	query := u.Query()
+	for _, p := range params {
+		kv := strings.SplitN(p, "=", 2)
+		if kv[0] == "" {
+			return nil, errors.Errorf("bad param %q, should be key=value", p)
+		}
+		if len(kv) == 1 {
+			// a flag like ?pretty needs no value
+			kv = append(kv, "")
+		}
+		query.Add(kv[0], kv[1])
+	}
+	u.RawQuery = query.Encode()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, errors.Errorf("bad header %q, should be 'Name: value'", h)
+		}
+		req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return req, nil
+}
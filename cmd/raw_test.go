@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+func TestNewRawRequest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		params  []string
+		headers []string
+		body    []byte
+		uri     string
+		err     string
+	}{
+		{name: "plain", method: "get", path: "_nodes/hot_threads", uri: "/_nodes/hot_threads"},
+		{name: "params", method: "GET", path: "/_cat/indices?v", params: []string{"s=index", "h=index,health"}, uri: "/_cat/indices?h=index%2Chealth&s=index&v="},
+		{name: "body", method: "PUT", path: "/noah/_settings", body: []byte(`{"index":{}}`), uri: "/noah/_settings"},
+		{name: "bad method", method: "PATCH", path: "/", err: `bad method "PATCH", one of GET, HEAD, POST, PUT, DELETE`},
+		{name: "bad param", method: "GET", path: "/", params: []string{"=v"}, err: `bad param "=v", should be key=value`},
+		{name: "bad header", method: "GET", path: "/", headers: []string{"Accept"}, err: `bad header "Accept", should be 'Name: value'`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := newRawRequest(tc.method, tc.path, tc.params, tc.headers, tc.body)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, strings.ToUpper(tc.method), req.Method)
+			require.Equal(t, tc.uri, req.URL.RequestURI())
+			if tc.body != nil {
+				require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+				require.Equal(t, int64(len(tc.body)), req.ContentLength)
+			}
+		})
+	}
+}
+
+func TestRawCommand(t *testing.T) {
+	mock := &fake.MockEsRecorder{}
+	_, err := executeCommand(`raw GET /_nodes/hot_threads --param threads=5 -H 'Accept: text/plain'`, mock)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodGet, mock.Last().Method)
+	require.Equal(t, "/_nodes/hot_threads?threads=5", mock.Last().URI)
+	require.Equal(t, "text/plain", mock.Last().Header.Get("Accept"))
+	require.Empty(t, mock.Last().Body)
+
+	_, err = executeCommand(`raw PUT /noah/_settings -d '{"index":{"number_of_replicas":2}}'`, mock)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPut, mock.Last().Method)
+	require.Equal(t, `{"index":{"number_of_replicas":2}}`, mock.Last().Body)
+
+	_, err = executeCommand(`raw PUT /noah/_settings -f ../pkg/testdata/raw.yaml`, mock)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"index":{"number_of_replicas":2}}`, mock.Last().Body)
+}
+
+func TestRawDeleteOnProtectedCluster(t *testing.T) {
+	defer func() { readConfirmation = es.Prompt }()
+	var prompted bool
+	readConfirmation = func(string) (string, error) {
+		prompted = true
+		return "qa", nil
+	}
+	protected := &es.ClusterInfo{Url: TestUrl, Username: TestUsername, Password: TestPassword, Protected: true}
+
+	mock := &fake.MockEsRecorder{}
+	_, err := executeCommandWithInfo("raw DELETE /noah", mock, protected)
+	require.EqualError(t, err, `"qa" is not the cluster name, nothing was done`)
+	require.True(t, prompted)
+	require.Empty(t, mock.Calls())
+
+	prompted = false
+	_, err = executeCommandWithInfo("raw GET /noah", mock, protected)
+	require.NoError(t, err)
+	require.False(t, prompted)
+
+	_, err = executeCommandWithInfo("raw delete /noah --yes", mock, protected)
+	require.NoError(t, err)
+	require.False(t, prompted)
+	require.Equal(t, []string{"GET /noah", "DELETE /noah"}, mock.Calls())
+}
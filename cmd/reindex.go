@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"strconv"
+	"time"
+)
+
+// minReindexPoll keeps --follow from hammering _tasks.
+const minReindexPoll = 100 * time.Millisecond
+
+// reindexOptions are the throttling and slicing of a reindex, zero values leaving es defaults.
+type reindexOptions struct {
+	requestsPerSecond int
+	slices            string
+}
+
+func (o reindexOptions) slicesValue() (interface{}, error) {
+	if o.slices == "" || o.slices == "auto" {
+		return o.slices, nil
+	}
+	n, err := strconv.Atoi(o.slices)
+	if err != nil || n < 1 {
+		return nil, errors.Errorf("bad --slices %q, a number or auto", o.slices)
+	}
+	return n, nil
+}
+
+func rethrottleReindex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		r                 = Reindex{Client: cli}
+		requestsPerSecond int
+		command           = &cobra.Command{
+			Use:   "rethrottle [task]",
+			Short: "change the requests per second of a running reindex",
+			Long:  "change the requests per second of a running reindex, -1 for no limit ... wordless",
+			Args:  cobra.ExactArgs(1),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) != 0 {
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				}
+				return r.taskIDs(), cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				res, err := r.rethrottle(args[0], requestsPerSecond)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	command.Flags().SetNormalizeFunc(flagAliases)
+	command.Flags().IntVar(&requestsPerSecond, "requests_per_second", 0, "new throttle of the reindex in sub-requests per second, -1 for no limit.")
+	_ = command.MarkFlagRequired("requests_per_second")
+	return command
+}
+
+func cancelReindex(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		r       = Reindex{Client: cli}
+		command = &cobra.Command{
+			Use:   "cancel [task]",
+			Short: "cancel a running reindex",
+			Long:  "cancel a running reindex, the docs already written stay ... wordless",
+			Args:  cobra.ExactArgs(1),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) != 0 {
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				}
+				return r.taskIDs(), cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				res, err := r.cancel(args[0])
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	return command
+}
+
+type Reindex struct {
+	Client *elasticsearch.Client
+	// poll is the time between two looks at a followed task
+	poll time.Duration
+}
+
+func (r *Reindex) rethrottle(task string, requestsPerSecond int) (*esapi.Response, error) {
+	return r.Client.ReindexRethrottle(task, &requestsPerSecond, r.Client.ReindexRethrottle.WithPretty())
+}
+
+func (r *Reindex) cancel(task string) (*esapi.Response, error) {
+	return r.Client.Tasks.Cancel(r.Client.Tasks.Cancel.WithTaskID(task), r.Client.Tasks.Cancel.WithPretty())
+}
+
+// taskIDs are the ids of the running reindex tasks, for completion.
+func (r *Reindex) taskIDs() []string {
+	res, err := r.Client.Tasks.List(
+		r.Client.Tasks.List.WithActions("*reindex"),
+		r.Client.Tasks.List.WithGroupBy("none"),
+	)
+	if err != nil || res.IsError() {
+		return nil
+	}
+	defer res.Body.Close()
+	var list struct {
+		Tasks []struct {
+			Node string `json:"node"`
+			ID   int64  `json:"id"`
+		} `json:"tasks"`
+	}
+	if json.NewDecoder(res.Body).Decode(&list) != nil {
+		return nil
+	}
+	var ids []string
+	for _, t := range list.Tasks {
+		ids = append(ids, fmt.Sprintf("%s:%d", t.Node, t.ID))
+	}
+	return ids
+}
+
+type reindexStatus struct {
+	Total            int64 `json:"total"`
+	Created          int64 `json:"created"`
+	Updated          int64 `json:"updated"`
+	Deleted          int64 `json:"deleted"`
+	Batches          int64 `json:"batches"`
+	VersionConflicts int64 `json:"version_conflicts"`
+	Noops            int64 `json:"noops"`
+}
+
+func (s reindexStatus) done() int64 {
+	return s.Created + s.Updated + s.Deleted + s.VersionConflicts + s.Noops
+}
+
+// reindexFailure is a doc, or a shard of the search, which failed to reindex.
+type reindexFailure struct {
+	Index  string         `json:"index"`
+	ID     string         `json:"id"`
+	Shard  *int           `json:"shard"`
+	Status int            `json:"status"`
+	Cause  *es.ErrorCause `json:"cause"`
+	Reason *es.ErrorCause `json:"reason"`
+}
+
+type reindexTask struct {
+	Completed bool `json:"completed"`
+	Task      struct {
+		Status             reindexStatus `json:"status"`
+		RunningTimeInNanos int64         `json:"running_time_in_nanos"`
+	} `json:"task"`
+	Response *reindexResponse `json:"response"`
+	Error    *es.ErrorCause   `json:"error"`
+}
+
+type reindexResponse struct {
+	reindexStatus
+	Took     int64            `json:"took"`
+	TimedOut bool             `json:"timed_out"`
+	Failures []reindexFailure `json:"failures"`
+}
+
+// follow polls the task until it completes, a line of progress per change, then
+// reports the outcome. Failures of the reindex make an error.
+func (r *Reindex) follow(ctx context.Context, out io.Writer, task string) error {
+	fmt.Fprintf(out, "following reindex task %s\n", task)
+	var last string
+	for {
+		t, err := r.get(ctx, task)
+		if err != nil {
+			return err
+		}
+		if t.Completed {
+			return r.report(out, t)
+		}
+		if line := reindexProgress(t.Task.Status, time.Duration(t.Task.RunningTimeInNanos)); line != last {
+			fmt.Fprintln(out, line)
+			last = line
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+func (r *Reindex) get(ctx context.Context, task string) (*reindexTask, error) {
+	res, err := r.Client.Tasks.Get(task, r.Client.Tasks.Get.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := es.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	t := new(reindexTask)
+	if err := json.NewDecoder(res.Body).Decode(t); err != nil {
+		return nil, errors.Wrap(err, "failed to parse reindex task")
+	}
+	return t, nil
+}
+
+func (r *Reindex) report(out io.Writer, t *reindexTask) error {
+	if t.Error != nil {
+		return errors.Errorf("reindex failed, %s: %s", t.Error.Type, t.Error.Reason)
+	}
+	if t.Response == nil {
+		return errors.New("reindex completed without a response")
+	}
+	s := t.Response.reindexStatus
+	fmt.Fprintf(out, "reindexed %d of %d docs in %s: %d created, %d updated, %d deleted, %d version conflicts, %d noops\n",
+		s.done(), s.Total, (time.Duration(t.Response.Took) * time.Millisecond).Round(time.Millisecond),
+		s.Created, s.Updated, s.Deleted, s.VersionConflicts, s.Noops)
+	if t.Response.TimedOut {
+		fmt.Fprintln(out, "some requests of the reindex timed out")
+	}
+	if len(t.Response.Failures) == 0 {
+		return nil
+	}
+	failed := make([]bulkItemResult, 0, len(t.Response.Failures))
+	for _, f := range t.Response.Failures {
+		item := bulkItemResult{Index: f.Index, ID: f.ID, Status: f.Status, Error: f.Cause}
+		if f.Shard != nil {
+			// a search failure, of a whole shard
+			item.ID = fmt.Sprintf("shard %d", *f.Shard)
+			item.Error = f.Reason
+		}
+		if item.Error == nil {
+			item.Error = &es.ErrorCause{Type: "unknown"}
+		}
+		failed = append(failed, item)
+	}
+	failures := &bulkResponse{}
+	for _, f := range failed {
+		failures.Items = append(failures.Items, map[string]bulkItemResult{"index": f})
+	}
+	printBulkFailures(out, failures.failures(), int(s.Total))
+	return errors.Errorf("%d failures reindexing", len(failed))
+}
+
+// reindexProgress is a line with the docs done out of total, the rate and the time left.
+func reindexProgress(s reindexStatus, running time.Duration) string {
+	line := fmt.Sprintf("%d created, %d updated, %d deleted", s.Created, s.Updated, s.Deleted)
+	if s.Total <= 0 {
+		return line + ", counting docs"
+	}
+	done := s.done()
+	line += fmt.Sprintf(" of %d docs %3d%%", s.Total, done*100/s.Total)
+	if done == 0 || running <= 0 {
+		return line
+	}
+	rate := float64(done) / running.Seconds()
+	eta := time.Duration(float64(s.Total-done) / rate * float64(time.Second))
+	return line + fmt.Sprintf(", %.0f docs/s, eta %s", rate, eta.Round(time.Second))
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+// reindexTransport starts a reindex task which completes on its second poll.
+type reindexTransport struct {
+	polls int
+	query string
+}
+
+func (t *reindexTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	respond := func(s string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(s))}, nil
+	}
+	switch req.URL.Path {
+	case "/_reindex":
+		t.query = req.URL.RawQuery
+		return respond(`{"task":"n1:12"}`)
+	case "/_tasks/n1:12":
+		t.polls++
+		if t.polls == 1 {
+			return respond(`{"completed":false,"task":{"running_time_in_nanos":2000000000,"status":{"total":1000,"created":200,"updated":100}}}`)
+		}
+		return respond(`{"completed":true,"task":{},"response":{"took":4000,"total":1000,"created":600,"updated":398,"failures":[
+{"index":"noah","id":"7","status":400,"cause":{"type":"mapper_parsing_exception","reason":"failed to parse field [age]"}},
+{"index":"noah","shard":2,"status":500,"reason":{"type":"node_closed_exception","reason":"node closed"}}]}}`)
+	}
+	return respond(`{}`)
+}
+
+func TestReindexFollow(t *testing.T) {
+	mock := &reindexTransport{}
+	o, err := executeCommand("index reindex noah noah-v2 --follow --poll_interval 100ms --requests_per_second 500 --slices auto", mock)
+	require.EqualError(t, err, "2 failures reindexing")
+	require.Contains(t, mock.query, "requests_per_second=500")
+	require.Contains(t, mock.query, "slices=auto")
+	require.Contains(t, mock.query, "wait_for_completion=false")
+	require.Equal(t, 2, mock.polls)
+	require.Contains(t, o, "following reindex task n1:12\n")
+	require.Contains(t, o, "200 created, 100 updated, 0 deleted of 1000 docs  30%, 150 docs/s, eta 5s\n")
+	require.Contains(t, o, "reindexed 998 of 1000 docs in 4s: 600 created, 398 updated, 0 deleted, 0 version conflicts, 0 noops\n")
+	require.Contains(t, o, "node_closed_exception      noah    shard 2   500      node closed")
+
+	_, err = executeCommand("index reindex noah noah-v2 --follow --poll_interval 0s", mock)
+	require.EqualError(t, err, "--poll_interval must be at least 100ms")
+}
+
+func TestReindexReport(t *testing.T) {
+	r := &Reindex{}
+	out := new(bytes.Buffer)
+	task := &reindexTask{Completed: true}
+	task.Response = new(reindexResponse)
+	task.Response.Total, task.Response.Created, task.Response.Took = 10, 10, 1500
+	require.NoError(t, r.report(out, task))
+	require.Equal(t, "reindexed 10 of 10 docs in 1.5s: 10 created, 0 updated, 0 deleted, 0 version conflicts, 0 noops\n", out.String())
+
+	task.Response = nil
+	require.EqualError(t, r.report(out, task), "reindex completed without a response")
+	task.Error = &es.ErrorCause{Type: "index_not_found_exception", Reason: "no such index [noah]"}
+	require.EqualError(t, r.report(out, task), "reindex failed, index_not_found_exception: no such index [noah]")
+}
+
+func TestReindexProgress(t *testing.T) {
+	require.Equal(t, "0 created, 0 updated, 0 deleted, counting docs", reindexProgress(reindexStatus{}, 0))
+	require.Equal(t, "0 created, 0 updated, 0 deleted of 100 docs   0%", reindexProgress(reindexStatus{Total: 100}, time.Second))
+	require.Equal(t, "40 created, 10 updated, 0 deleted of 100 docs  50%, 10 docs/s, eta 5s",
+		reindexProgress(reindexStatus{Total: 100, Created: 40, Updated: 10}, 5*time.Second))
+}
+
+func TestReindexSlices(t *testing.T) {
+	for slices, want := range map[string]interface{}{"": "", "auto": "auto", "4": 4} {
+		v, err := reindexOptions{slices: slices}.slicesValue()
+		require.NoError(t, err)
+		require.Equal(t, want, v)
+	}
+	_, err := reindexOptions{slices: "many"}.slicesValue()
+	require.EqualError(t, err, `bad --slices "many", a number or auto`)
+	_, err = reindexOptions{slices: "0"}.slicesValue()
+	require.Error(t, err)
+}
+
+func TestReindexRethrottleAndCancel(t *testing.T) {
+	mock := &fake.MockEsResponse{ResponseString: `{"nodes":{}}`}
+	_, err := executeCommand("index reindex rethrottle n1:12 --requests-per-second -1", mock)
+	require.NoError(t, err)
+	_, err = executeCommand("index reindex rethrottle n1:12", mock)
+	require.Error(t, err)
+	_, err = executeCommand("index reindex cancel n1:12", mock)
+	require.NoError(t, err)
+}
+
+func TestReindexFlagAliases(t *testing.T) {
+	command := rethrottleReindex(nil, new(bytes.Buffer))
+	require.NoError(t, command.ParseFlags([]string{"--requests-per-second", "-1"}))
+	rps, err := command.Flags().GetInt("requests_per_second")
+	require.NoError(t, err)
+	require.Equal(t, -1, rps)
+
+	command = index(nil, new(bytes.Buffer))
+	reindex, _, err := command.Find([]string{"reindex"})
+	require.NoError(t, err)
+	require.NoError(t, reindex.ParseFlags([]string{"--poll-interval", "1s", "--requests_per_second", "500"}))
+	poll, err := reindex.Flags().GetDuration("poll_interval")
+	require.NoError(t, err)
+	require.Equal(t, time.Second, poll)
+}
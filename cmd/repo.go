@@ -40,10 +40,10 @@ func getRepos(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := so.getRepoAllSnapshots(args[0], snapshots)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -76,10 +76,10 @@ func createRepo(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := so.createSnapshotRepo(containType, container, path, args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -96,6 +96,7 @@ func createRepo(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 func deleteRepo(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		so      = Snapshot{client: cli}
+		yes     bool
 		command = &cobra.Command{
 			Use:   "delete [repository]",
 			Short: "delete specific snapshots ",
@@ -108,14 +109,18 @@ func deleteRepo(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				return so.getAllRepos(), cobra.ShellCompDirectiveNoFileComp
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := confirmDestructive(out, yes, "delete repositories", func() []string { return splitWords(args[0]) }); err != nil {
+					return err
+				}
 				res, err := so.deleteSnapshotRepo(args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
+	addYesFlag(command, &yes)
 	return command
 }
 
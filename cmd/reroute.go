@@ -56,10 +56,10 @@ func rerouteMoveIndex(cli *elasticsearch.Client, out io.Writer, osArgs []string)
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := r.move(args[0], shard, fromNode, toNode, req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -113,10 +113,10 @@ func rerouteAllocateReplicas(cli *elasticsearch.Client, out io.Writer, osArgs []
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := r.allocateReplicaOrCancel(AllocateReplicasOps, args[0], shard, node, req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -160,10 +160,10 @@ func cancel(cli *elasticsearch.Client, out io.Writer, osArgs []string) *cobra.Co
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := r.allocateReplicaOrCancel(CancelOps, args[0], shard, node, req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -198,10 +198,10 @@ func failed(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := r.retryFailed()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -1,19 +1,30 @@
 package cmd
 
 import (
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
 	"io"
+	"log"
+	"strings"
 )
 
-var resources = []string{"health", "nodes", "allocations", "threadpool", "cachemem", "segmem", "largeindices", "allocationExp"}
+var (
+	resources = []string{"health", "nodes", "allocations", "threadpool", "cachemem", "segmem", "largeindices", "allocationExp",
+		"shards", "recovery", "pending_tasks", "indices", "segments", "fielddata", "plugins", "count", "aliases", "templates", "master"}
+	indexHealth = []string{"green", "yellow", "red"}
+	indexStatus = []string{"open", "close"}
+	// statusWildcards maps an index status to the expand_wildcards matching it
+	statusWildcards = map[string]string{"open": "open", "close": "closed"}
+)
 
 func catClusterResources(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var opts catOptions
 	var command = &cobra.Command{
 		Use:   "cat [resource]",
-		Short: "cat allocation/nodes/health/nodes/threadpool/cache memory/segments memory/large indices.",
+		Short: "cat allocation/nodes/health/threadpool/cache memory/segments memory/large indices/allocation explanation/shards/recovery/pending tasks/indices/segments/fielddata/plugins/count/aliases/templates/master.",
 		Long:  "cat es cluster info ... wordless",
 		Args:  cobra.ExactArgs(1),
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -23,18 +34,52 @@ func catClusterResources(cli *elasticsearch.Client, out io.Writer) *cobra.Comman
 			return resources, cobra.ShellCompDirectiveNoFileComp
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			res, err := catResources(args[0], cli)
-			if err == nil {
-				fmt.Fprintln(out, res)
+			if (opts.Health != "" || opts.Status != "") && args[0] != "indices" {
+				return errors.Errorf("--health and --status only apply to cat indices, not %s", args[0])
+			}
+			if opts.Health != "" && !es.Check(opts.Health, indexHealth) {
+				return errors.Errorf("bad health %q, want one of %s", opts.Health, strings.Join(indexHealth, "|"))
+			}
+			if opts.Status != "" && !es.Check(opts.Status, indexStatus) {
+				return errors.Errorf("bad status %q, want one of %s", opts.Status, strings.Join(indexStatus, "|"))
+			}
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			if output != "" {
+				opts.Format = "json"
+			}
+			res, err := catResources(args[0], cli, opts)
+			if err != nil {
+				return err
 			}
-			return err
+			return printResponse(out, res, nil)
 		},
 	}
+	addCatFlags(command, cli, &opts)
+	f := command.Flags()
+	f.StringVar(&opts.Health, "health", "", "only cat indices of this health, one of green|yellow|red.")
+	f.StringVar(&opts.Status, "status", "", "only cat indices of this status, one of open|close.")
+	err := command.RegisterFlagCompletionFunc("health", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return indexHealth, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = command.RegisterFlagCompletionFunc("status", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return indexStatus, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 	return command
 }
 
-func catResources(resource string, cli *elasticsearch.Client) (res *esapi.Response, err error) {
-	return NewCatStrategy(resource, cli).Cat()
+func catResources(resource string, cli *elasticsearch.Client, opts catOptions) (res *esapi.Response, err error) {
+	if err := es.Validate(resource, resources); err != nil {
+		return nil, es.NoResourcesError(resource)
+	}
+	return NewCatStrategy(resource, cli, opts).Cat()
 }
 
 type CatStrategy struct {
@@ -42,96 +87,358 @@ type CatStrategy struct {
 }
 
 func (c *CatStrategy) Cat() (res *esapi.Response, err error) {
-	return c.Strategy.cat()
+	if c.Strategy == nil {
+		return nil, errors.New("no cat strategy for the resource")
+	}
+	o := c.Strategy.options()
+	if !o.filtered() {
+		return c.Strategy.cat()
+	}
+	// rows are filtered as json, then rendered as asked for
+	format := o.Format
+	o.Format = "json"
+	res, err = c.Strategy.cat()
+	o.Format = format
+	if err != nil || res.IsError() {
+		return res, err
+	}
+	return o.filter(res, format)
 }
 
 type CatResource interface {
 	cat() (res *esapi.Response, err error)
+	options() *catOptions
 }
 
 type health struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *health) cat() (res *esapi.Response, err error) {
-	return o.Client.Cat.Health(o.Client.Cat.Health.WithV(true))
+	return o.Client.Cat.Health(
+		o.Client.Cat.Health.WithV(true),
+		o.Client.Cat.Health.WithFormat(o.Format),
+		o.Client.Cat.Health.WithHelp(o.Help),
+		o.Client.Cat.Health.WithH(o.columns()...),
+		o.Client.Cat.Health.WithS(o.Sort...),
+	)
 }
 
 type nodes struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *nodes) cat() (res *esapi.Response, err error) {
-	return o.Client.Cat.Nodes(o.Client.Cat.Nodes.WithV(true))
+	return o.Client.Cat.Nodes(
+		o.Client.Cat.Nodes.WithV(true),
+		o.Client.Cat.Nodes.WithFormat(o.Format),
+		o.Client.Cat.Nodes.WithHelp(o.Help),
+		o.Client.Cat.Nodes.WithH(o.columns()...),
+		o.Client.Cat.Nodes.WithS(o.Sort...),
+		o.Client.Cat.Nodes.WithBytes(o.Bytes),
+	)
 }
 
 type allocations struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *allocations) cat() (res *esapi.Response, err error) {
-	return o.Client.Cat.Allocation(o.Client.Cat.Allocation.WithV(true))
+	return o.Client.Cat.Allocation(
+		o.Client.Cat.Allocation.WithV(true),
+		o.Client.Cat.Allocation.WithFormat(o.Format),
+		o.Client.Cat.Allocation.WithHelp(o.Help),
+		o.Client.Cat.Allocation.WithH(o.columns()...),
+		o.Client.Cat.Allocation.WithS(o.Sort...),
+		o.Client.Cat.Allocation.WithBytes(o.Bytes),
+	)
 }
 
 type threadpool struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *threadpool) cat() (res *esapi.Response, err error) {
-	return o.Client.Cat.ThreadPool(o.Client.Cat.ThreadPool.WithV(true))
+	return o.Client.Cat.ThreadPool(
+		o.Client.Cat.ThreadPool.WithV(true),
+		o.Client.Cat.ThreadPool.WithFormat(o.Format),
+		o.Client.Cat.ThreadPool.WithHelp(o.Help),
+		o.Client.Cat.ThreadPool.WithH(o.columns()...),
+		o.Client.Cat.ThreadPool.WithS(o.Sort...),
+	)
 }
 
 type cacheMemory struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *cacheMemory) cat() (res *esapi.Response, err error) {
 	return o.Client.Cat.Nodes(
 		o.Client.Cat.Nodes.WithV(true),
-		o.Client.Cat.Nodes.WithH("name", "queryCacheMemory", "queryCacheEvictions", "requestCacheMemory", "requestCacheHitCount", "request_cache.miss_count"),
+		o.Client.Cat.Nodes.WithFormat(o.Format),
+		o.Client.Cat.Nodes.WithHelp(o.Help),
+		o.Client.Cat.Nodes.WithH(o.columns("name", "queryCacheMemory", "queryCacheEvictions", "requestCacheMemory", "requestCacheHitCount", "request_cache.miss_count")...),
+		o.Client.Cat.Nodes.WithS(o.Sort...),
+		o.Client.Cat.Nodes.WithBytes(o.Bytes),
 	)
 }
 
 type segmentsMemory struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *segmentsMemory) cat() (res *esapi.Response, err error) {
 	return o.Client.Cat.Nodes(
 		o.Client.Cat.Nodes.WithV(true),
-		o.Client.Cat.Nodes.WithH("name", "segments.memory", "segments.index_writer_memory", "fielddata.memory_size", "query_cache.memory_size", "request_cache.memory_size"),
+		o.Client.Cat.Nodes.WithFormat(o.Format),
+		o.Client.Cat.Nodes.WithHelp(o.Help),
+		o.Client.Cat.Nodes.WithH(o.columns("name", "segments.memory", "segments.index_writer_memory", "fielddata.memory_size", "query_cache.memory_size", "request_cache.memory_size")...),
+		o.Client.Cat.Nodes.WithS(o.Sort...),
+		o.Client.Cat.Nodes.WithBytes(o.Bytes),
 	)
 }
 
 type largeIndices struct {
 	Client *elasticsearch.Client
+	catOptions
 }
 
 func (o *largeIndices) cat() (res *esapi.Response, err error) {
 	return o.Client.Cat.Indices(
 		o.Client.Cat.Indices.WithV(true),
-		o.Client.Cat.Indices.WithH("store.size", "index"),
-		o.Client.Cat.Indices.WithBytes("gb"),
+		o.Client.Cat.Indices.WithFormat(o.Format),
+		o.Client.Cat.Indices.WithHelp(o.Help),
+		o.Client.Cat.Indices.WithH(o.columns("store.size", "index")...),
+		o.Client.Cat.Indices.WithS(o.sort("store.size:desc")...),
+		o.Client.Cat.Indices.WithBytes(o.bytes("gb")),
+	)
+}
+
+type catShards struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catShards) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Shards(
+		o.Client.Cat.Shards.WithV(true),
+		o.Client.Cat.Shards.WithFormat(o.Format),
+		o.Client.Cat.Shards.WithHelp(o.Help),
+		o.Client.Cat.Shards.WithH(o.columns("index", "shard", "prirep", "state", "docs", "store", "node", "unassigned.reason")...),
+		o.Client.Cat.Shards.WithS(o.Sort...),
+		o.Client.Cat.Shards.WithBytes(o.Bytes),
+	)
+}
+
+type catRecovery struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catRecovery) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Recovery(
+		o.Client.Cat.Recovery.WithV(true),
+		o.Client.Cat.Recovery.WithFormat(o.Format),
+		o.Client.Cat.Recovery.WithHelp(o.Help),
+		o.Client.Cat.Recovery.WithH(o.columns("index", "shard", "time", "type", "stage", "source_node", "target_node", "bytes_percent", "translog_ops_percent")...),
+		o.Client.Cat.Recovery.WithS(o.Sort...),
+		o.Client.Cat.Recovery.WithBytes(o.Bytes),
+		o.Client.Cat.Recovery.WithActiveOnly(true),
+	)
+}
+
+type catPendingTasks struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catPendingTasks) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.PendingTasks(
+		o.Client.Cat.PendingTasks.WithV(true),
+		o.Client.Cat.PendingTasks.WithFormat(o.Format),
+		o.Client.Cat.PendingTasks.WithHelp(o.Help),
+		o.Client.Cat.PendingTasks.WithH(o.columns("insertOrder", "timeInQueue", "priority", "source")...),
+		o.Client.Cat.PendingTasks.WithS(o.Sort...),
+	)
+}
+
+type catIndices struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catIndices) cat() (res *esapi.Response, err error) {
+	opts := []func(*esapi.CatIndicesRequest){
+		o.Client.Cat.Indices.WithV(true),
+		o.Client.Cat.Indices.WithFormat(o.Format),
+		o.Client.Cat.Indices.WithHelp(o.Help),
+		o.Client.Cat.Indices.WithH(o.columns("health", "status", "index", "pri", "rep", "docs.count", "store.size", "pri.store.size")...),
+		o.Client.Cat.Indices.WithS(o.Sort...),
+		o.Client.Cat.Indices.WithBytes(o.Bytes),
+	}
+	if o.Health != "" {
+		opts = append(opts, o.Client.Cat.Indices.WithHealth(o.Health))
+	}
+	if o.Status != "" {
+		opts = append(opts, o.Client.Cat.Indices.WithExpandWildcards(statusWildcards[o.Status]))
+	}
+	return o.Client.Cat.Indices(opts...)
+}
+
+type catSegments struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catSegments) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Segments(
+		o.Client.Cat.Segments.WithV(true),
+		o.Client.Cat.Segments.WithFormat(o.Format),
+		o.Client.Cat.Segments.WithHelp(o.Help),
+		o.Client.Cat.Segments.WithH(o.columns("index", "shard", "prirep", "segment", "docs.count", "size", "size.memory", "committed", "searchable")...),
+		o.Client.Cat.Segments.WithS(o.Sort...),
+		o.Client.Cat.Segments.WithBytes(o.Bytes),
+	)
+}
+
+type catFielddata struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catFielddata) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Fielddata(
+		o.Client.Cat.Fielddata.WithV(true),
+		o.Client.Cat.Fielddata.WithFormat(o.Format),
+		o.Client.Cat.Fielddata.WithHelp(o.Help),
+		o.Client.Cat.Fielddata.WithH(o.columns("node", "field", "size")...),
+		o.Client.Cat.Fielddata.WithS(o.Sort...),
+		o.Client.Cat.Fielddata.WithBytes(o.Bytes),
+	)
+}
+
+type catPlugins struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catPlugins) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Plugins(
+		o.Client.Cat.Plugins.WithV(true),
+		o.Client.Cat.Plugins.WithFormat(o.Format),
+		o.Client.Cat.Plugins.WithHelp(o.Help),
+		o.Client.Cat.Plugins.WithH(o.columns("name", "component", "version")...),
+		o.Client.Cat.Plugins.WithS(o.Sort...),
+	)
+}
+
+type catCount struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catCount) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Count(
+		o.Client.Cat.Count.WithV(true),
+		o.Client.Cat.Count.WithFormat(o.Format),
+		o.Client.Cat.Count.WithHelp(o.Help),
+		o.Client.Cat.Count.WithH(o.columns()...),
+		o.Client.Cat.Count.WithS(o.Sort...),
+	)
+}
+
+type catAliases struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catAliases) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Aliases(
+		o.Client.Cat.Aliases.WithV(true),
+		o.Client.Cat.Aliases.WithFormat(o.Format),
+		o.Client.Cat.Aliases.WithHelp(o.Help),
+		o.Client.Cat.Aliases.WithH(o.columns("alias", "index", "filter", "routing.index", "routing.search", "is_write_index")...),
+		o.Client.Cat.Aliases.WithS(o.Sort...),
+	)
+}
+
+type catTemplates struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catTemplates) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Templates(
+		o.Client.Cat.Templates.WithV(true),
+		o.Client.Cat.Templates.WithFormat(o.Format),
+		o.Client.Cat.Templates.WithHelp(o.Help),
+		o.Client.Cat.Templates.WithH(o.columns("name", "index_patterns", "order", "version", "composed_of")...),
+		o.Client.Cat.Templates.WithS(o.Sort...),
+	)
+}
+
+type catMaster struct {
+	Client *elasticsearch.Client
+	catOptions
+}
+
+func (o *catMaster) cat() (res *esapi.Response, err error) {
+	return o.Client.Cat.Master(
+		o.Client.Cat.Master.WithV(true),
+		o.Client.Cat.Master.WithFormat(o.Format),
+		o.Client.Cat.Master.WithHelp(o.Help),
+		o.Client.Cat.Master.WithH(o.columns()...),
+		o.Client.Cat.Master.WithS(o.Sort...),
 	)
 }
 
-func NewCatStrategy(resource string, cli *elasticsearch.Client) *CatStrategy {
+func NewCatStrategy(resource string, cli *elasticsearch.Client, opts catOptions) *CatStrategy {
 	strategy := new(CatStrategy)
 	switch resource {
 	case "health":
-		strategy.Strategy = &health{Client: cli}
+		strategy.Strategy = &health{Client: cli, catOptions: opts}
 	case "nodes":
-		strategy.Strategy = &nodes{Client: cli}
+		strategy.Strategy = &nodes{Client: cli, catOptions: opts}
 	case "allocations":
-		strategy.Strategy = &allocations{Client: cli}
+		strategy.Strategy = &allocations{Client: cli, catOptions: opts}
 	case "threadpool":
-		strategy.Strategy = &threadpool{Client: cli}
+		strategy.Strategy = &threadpool{Client: cli, catOptions: opts}
 	case "cachemem":
-		strategy.Strategy = &cacheMemory{Client: cli}
+		strategy.Strategy = &cacheMemory{Client: cli, catOptions: opts}
 	case "segmem":
-		strategy.Strategy = &segmentsMemory{Client: cli}
+		strategy.Strategy = &segmentsMemory{Client: cli, catOptions: opts}
 	case "largeindices":
-		strategy.Strategy = &largeIndices{Client: cli}
+		strategy.Strategy = &largeIndices{Client: cli, catOptions: opts}
+	case "allocationExp":
+		strategy.Strategy = &allocationExplanation{Client: cli, catOptions: opts}
+	case "shards":
+		strategy.Strategy = &catShards{Client: cli, catOptions: opts}
+	case "recovery":
+		strategy.Strategy = &catRecovery{Client: cli, catOptions: opts}
+	case "pending_tasks":
+		strategy.Strategy = &catPendingTasks{Client: cli, catOptions: opts}
+	case "indices":
+		strategy.Strategy = &catIndices{Client: cli, catOptions: opts}
+	case "segments":
+		strategy.Strategy = &catSegments{Client: cli, catOptions: opts}
+	case "fielddata":
+		strategy.Strategy = &catFielddata{Client: cli, catOptions: opts}
+	case "plugins":
+		strategy.Strategy = &catPlugins{Client: cli, catOptions: opts}
+	case "count":
+		strategy.Strategy = &catCount{Client: cli, catOptions: opts}
+	case "aliases":
+		strategy.Strategy = &catAliases{Client: cli, catOptions: opts}
+	case "templates":
+		strategy.Strategy = &catTemplates{Client: cli, catOptions: opts}
+	case "master":
+		strategy.Strategy = &catMaster{Client: cli, catOptions: opts}
 	}
 	return strategy
 }
@@ -56,6 +56,78 @@ var _ = Describe("cat resources test", func() {
 						ResponseString: `{"test":"get largeindices"}`,
 					},
 				},
+				{
+					cmd: "cat shards",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get shards"}`,
+					},
+				},
+				{
+					cmd: "cat recovery",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get recovery"}`,
+					},
+				},
+				{
+					cmd: "cat pending_tasks",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get pending_tasks"}`,
+					},
+				},
+				{
+					cmd: "cat indices",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get indices"}`,
+					},
+				},
+				{
+					cmd: "cat indices --health yellow --status open",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get indices"}`,
+					},
+				},
+				{
+					cmd: "cat segments",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get segments"}`,
+					},
+				},
+				{
+					cmd: "cat fielddata",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get fielddata"}`,
+					},
+				},
+				{
+					cmd: "cat plugins",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get plugins"}`,
+					},
+				},
+				{
+					cmd: "cat count",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get count"}`,
+					},
+				},
+				{
+					cmd: "cat aliases",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get aliases"}`,
+					},
+				},
+				{
+					cmd: "cat templates",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get templates"}`,
+					},
+				},
+				{
+					cmd: "cat master",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get master"}`,
+					},
+				},
 			}
 
 			for _, tc := range testCases {
@@ -75,11 +147,64 @@ var _ = Describe("cat resources test", func() {
 						ResponseString: `{"test":"get health"}`,
 					},
 				},
+				{
+					cmd: "cat indices --health blue",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get indices"}`,
+					},
+				},
+				{
+					cmd: "cat indices --status deleted",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get indices"}`,
+					},
+				},
+				{
+					cmd: "cat nodes --health red",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get nodes"}`,
+					},
+				},
+				{
+					cmd: "cat shards --status open",
+					mock: &fake.MockEsResponse{
+						ResponseString: `{"test":"get shards"}`,
+					},
+				},
 			}
 			for _, tc := range testCases {
 				_, err := executeCommand(tc.cmd, tc.mock)
 				Expect(err).ShouldNot(BeNil())
 			}
 		})
+		It("test cat command with output format", func() {
+			mock := &fake.MockEsResponse{
+				ResponseString: `[{"cluster":"noah","status":"green","node.total":"3"}]`,
+			}
+			testCases := []struct {
+				cmd  string
+				want string
+			}{
+				{
+					cmd:  "cat health -o table",
+					want: "CLUSTER   STATUS   NODE.TOTAL\nnoah      green    3\n",
+				},
+				{
+					cmd:  "cat health -o name",
+					want: "noah\n",
+				},
+				{
+					cmd:  "cat health --output yaml",
+					want: "- cluster: noah\n  node.total: \"3\"\n  status: green\n",
+				},
+			}
+			for _, tc := range testCases {
+				out, err := executeCommand(tc.cmd, mock)
+				Expect(err).Should(BeNil())
+				Expect(out).Should(Equal(tc.want))
+			}
+			_, err := executeCommand("cat health -o xml", mock)
+			Expect(err).ShouldNot(BeNil())
+		})
 	})
 })
@@ -3,7 +3,6 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/pkg/errors"
@@ -60,10 +59,10 @@ func getRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				} else {
 					res, err = r.getRoles(args[0])
 				}
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, roleLayout)
 			},
 		}
 	)
@@ -90,10 +89,10 @@ func createRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				}
 				r.Role = args[0]
 				res, err := r.createRole(req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -154,10 +153,10 @@ func updateRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				}
 				r.Role = args[0]
 				res, err := r.updateRole(req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -197,6 +196,7 @@ func updateRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 func deleteRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		r       = &Role{Client: cli}
+		yes     bool
 		command = &cobra.Command{
 			Use:   "delete [role]",
 			Short: "delete specify role",
@@ -209,14 +209,18 @@ func deleteRole(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				return r.getAllRoles(), cobra.ShellCompDirectiveNoFileComp
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := confirmDestructive(out, yes, "delete role", func() []string { return []string{args[0]} }); err != nil {
+					return err
+				}
 				res, err := r.deleteRole(args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
+	addYesFlag(command, &yes)
 	return command
 }
 
@@ -2,17 +2,26 @@
 package cmd
 
 import (
+	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/toughnoah/blackbean/pkg/es"
+	"github.com/toughnoah/blackbean/pkg/printer"
 	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
+const skipClientAnnotation = "blackbean/skip-client"
+
 var (
-	cfgFile string
+	cfgFile      string
+	clusterName  string
+	output       string
+	templateFile string
 )
 
 func NewRootCmd(transport http.RoundTripper, out io.Writer, in io.ReadWriter, fd int, args []string) *cobra.Command {
@@ -25,52 +34,115 @@ Besides, blackbean is the name of my favorite french bulldog.`,
 	}
 	flags := rootCmd.PersistentFlags()
 	flags.StringVar(&cfgFile, "config", "", "config file (default is $HOME/.blackbean.yaml)")
+	flags.StringVarP(&clusterName, "cluster", "c", "", "cluster to talk to for this invocation, overrides 'current' (env BLACKBEAN_CLUSTER)")
+	if err := rootCmd.RegisterFlagCompletionFunc("cluster", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}); err != nil {
+		log.Fatal(err)
+	}
+	flags.StringVarP(&output, "output", "o", "", "output format, one of json|yaml|table|wide|name|jsonpath=...|go-template=... (default is the raw response)")
+	if err := rootCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		formats := append([]string{}, printer.Formats...)
+		for _, f := range printer.TemplateFormats {
+			formats = append(formats, f+"=")
+		}
+		return formats, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}); err != nil {
+		log.Fatal(err)
+	}
+	flags.BoolVarP(&watch, "watch", "w", false, "re-run a read-only command every --interval, highlighting what changed, until Ctrl-C")
+	flags.DurationVar(&watchInterval, "interval", 2*time.Second, "refresh interval of --watch")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the requests which would change the cluster, and a diff against its current state, instead of sending them")
+	flags.StringVar(&templateFile, "template-file", "", "file holding the template of -o jsonpath or -o go-template (default is go-template)")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
 	// We can safely ignore any errors that flags.Parse encounters since
 	// those errors will be caught later during the call to cmd.Execution.
-	// This call is required to gather configuration information prior to
-	// execution.
+	// This call is required to gather configuration information for
+	// shell completion, which does not parse flags itself.
 	flags.ParseErrorsWhitelist.UnknownFlags = true
 	_ = flags.Parse(args)
-	InitConfig()
-	profile, err := es.GetProfile()
-	if err != nil {
-		log.Fatalf("Get Profile error %v", err)
-	}
+	_ = viper.BindPFlag(es.OverrideSpec, flags.Lookup("cluster"))
+	_ = viper.BindEnv(es.OverrideSpec, es.ClusterEnv)
 
-	cli, err := es.NewEsClient(profile.ClusterInfo.Url, profile.ClusterInfo.Username, profile.ClusterInfo.Password, transport)
-	if err != nil {
-		log.Fatalf("New client error %v", err)
+	// the client is wired to a cluster in PersistentPreRunE, so that commands
+	// which do not talk to es work without a reachable or valid profile.
+	cli := es.NewLazyClient()
+	// commands write to the screen, which --watch redraws on every refresh
+	scr := newScreen(out)
+	out = scr
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		if isCompletionRequest(cmd) {
+			// completion is best effort, a broken profile just yields no suggestions,
+			// and a TAB never prompts for a password nor runs password_command
+			if InitConfig() == nil {
+				es.SetNoPrompt(true)
+				_ = initClient(cli, transport, in, fd)
+				es.SetNoPrompt(false)
+			}
+			return nil
+		}
+		if err := resolveOutput(); err != nil {
+			return err
+		}
+		if watch {
+			if err := startWatch(cmd, scr); err != nil {
+				return err
+			}
+		}
+		if !needsClient(cmd) {
+			_ = InitConfig()
+			return nil
+		}
+		if err := InitConfig(); err != nil {
+			return err
+		}
+		var wrap []func(http.RoundTripper) http.RoundTripper
+		if dryRun {
+			wrap = append(wrap, func(next http.RoundTripper) http.RoundTripper {
+				return &dryRunTransport{next: next, out: out}
+			})
+		}
+		// innermost, so that only what is really sent is audited, along with
+		// the whole command line rather than the args left after the flags
+		wrap = append(wrap, auditing(args))
+		return initClient(cli, transport, in, fd, wrap...)
 	}
-	rootCmd.AddCommand(NewCompletionCmd(out))
-	rootCmd.AddCommand(catClusterResources(cli, out))
+	rootCmd.AddCommand(skipClient(NewCompletionCmd(out)))
+	rootCmd.AddCommand(watchable(catClusterResources(cli, out)))
 	rootCmd.AddCommand(apply(cli, out, args))
 	rootCmd.AddCommand(snapshot(cli, out))
 	rootCmd.AddCommand(repo(cli, out))
-	rootCmd.AddCommand(useCluster(out))
-	rootCmd.AddCommand(current(out))
+	rootCmd.AddCommand(skipClient(useCluster(out)))
+	rootCmd.AddCommand(skipClient(current(out)))
+	rootCmd.AddCommand(skipClient(clusterConfig(out)))
 	rootCmd.AddCommand(index(cli, out))
 	rootCmd.AddCommand(alias(cli, out))
 	rootCmd.AddCommand(reroute(cli, out, args))
 	rootCmd.AddCommand(watcher(cli, out))
-	rootCmd.AddCommand(explain(cli, out, args))
+	rootCmd.AddCommand(watchable(explain(cli, out, args)))
 	rootCmd.AddCommand(user(cli, out, in, fd))
 	rootCmd.AddCommand(role(cli, out))
 	rootCmd.AddCommand(template(cli, out))
+	rootCmd.AddCommand(task(cli, out))
+	rootCmd.AddCommand(settings(cli, out, otherClusters(transport)))
+	rootCmd.AddCommand(skipClient(auditCmd(out)))
+	rootCmd.AddCommand(raw(cli, out))
 	return rootCmd
 }
 
-func InitConfig() {
+func InitConfig() error {
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
 		// Find home directory.
 		home, err := homedir.Dir()
-		cobra.CheckErr(err)
+		if err != nil {
+			return err
+		}
 
 		// Search config in home directory with name ".blackbean" (without extension).
 		viper.AddConfigPath(home)
@@ -81,6 +153,69 @@ func InitConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err != nil {
-		log.Fatal("ERROR: ", "can't not read config file! ", err)
+		return errors.Wrap(err, "can't not read config file")
+	}
+	return nil
+}
+
+// initClient wires cli to the current cluster, wrap decorates the transport from the outside in.
+func initClient(cli *elasticsearch.Client, transport http.RoundTripper, in io.ReadWriter, fd int, wrap ...func(http.RoundTripper) http.RoundTripper) error {
+	es.SetTerminal(in, fd)
+	profile, err := es.GetProfile()
+	if err != nil {
+		return errors.Wrap(err, "get profile error")
+	}
+	built, err := newClient(profile, transport, wrap...)
+	if err != nil {
+		return err
+	}
+	es.InitLazyClient(cli, built)
+	currentProfile = profile
+	return nil
+}
+
+func newClient(profile *es.Profile, transport http.RoundTripper, wrap ...func(http.RoundTripper) http.RoundTripper) (*elasticsearch.Client, error) {
+	if transport == nil {
+		transport = es.NewTransport(profile.TLSConfig)
+	}
+	for i := len(wrap) - 1; i >= 0; i-- {
+		transport = wrap[i](transport)
+	}
+	return es.NewEsClientFromInfo(profile.ClusterInfo, transport)
+}
+
+// otherClusters builds clients of clusters other than the current one, for commands comparing clusters.
+func otherClusters(transport http.RoundTripper) clusterClient {
+	return func(name string) (*elasticsearch.Client, error) {
+		profile, err := es.GetClusterProfile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get profile of %q error", name)
+		}
+		return newClient(profile, transport)
 	}
 }
+
+// skipClient marks a command, and all of its subcommands, as not talking to es.
+func skipClient(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[skipClientAnnotation] = "true"
+	return cmd
+}
+
+func needsClient(cmd *cobra.Command) bool {
+	if cmd.Name() == "help" {
+		return false
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[skipClientAnnotation]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isCompletionRequest(cmd *cobra.Command) bool {
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
+}
@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"github.com/mitchellh/go-homedir"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/prashantv/gostub"
 	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
 	"path/filepath"
+	"testing"
 )
 
 var _ = Describe("cat resources test", func() {
@@ -36,7 +40,7 @@ var _ = Describe("cat resources test", func() {
 					stubs.Stub(&cfgFile, file)
 				}
 				Expect(createErr).To(BeNil())
-				InitConfig()
+				Expect(InitConfig()).To(BeNil())
 				deleteErr := fs.Remove(file)
 				Expect(deleteErr).To(BeNil())
 				stubs.Reset()
@@ -44,3 +48,45 @@ var _ = Describe("cat resources test", func() {
 		})
 	})
 })
+
+func TestLazyClient(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "nosuch.yaml")
+	testCases := []struct {
+		name string
+		args []string
+		err  bool
+	}{
+		{
+			name: "completion works without config",
+			args: []string{"completion", "bash", "--config", missing},
+		},
+		{
+			name: "help works without config",
+			args: []string{"cat", "--help", "--config", missing},
+		},
+		{
+			name: "shell completion works without config",
+			args: []string{cobra.ShellCompRequestCmd, "--config", missing, "index", "get", ""},
+		},
+		{
+			name: "commands talking to es report the config error",
+			args: []string{"cat", "health", "--config", missing},
+			err:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			root := NewRootCmd(nil, buf, nil, 0, tc.args)
+			root.SetOut(buf)
+			root.SetErr(buf)
+			root.SetArgs(tc.args)
+			err := root.Execute()
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,243 +1,396 @@
 package cmd
 
-type Settings struct {
-	Persistent *persistent `json:"persistent"`
-}
-
-type persistent struct {
-	Cluster *cluster `json:"cluster,omitempty"`
-	Script  *script  `json:"script,omitempty"`
-	Indices *indices `json:"indices,omitempty"`
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/toughnoah/blackbean/pkg/es"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	persistentSettings = "persistent"
+	transientSettings  = "transient"
+)
+
+// clusterClient returns a client of a cluster of the config, other than the current one.
+type clusterClient func(name string) (*elasticsearch.Client, error)
+
+func settings(cli *elasticsearch.Client, out io.Writer, clusters clusterClient) *cobra.Command {
+	var (
+		command = &cobra.Command{
+			Use:               "settings [subcommand]",
+			Short:             "cluster settings operations",
+			Long:              "cluster settings operations ... wordless",
+			Args:              cobra.NoArgs,
+			ValidArgsFunction: noCompletions,
+		}
+	)
+	command.AddCommand(watchable(getSettings(cli, out)))
+	command.AddCommand(setSettings(cli, out))
+	command.AddCommand(resetSettings(cli, out))
+	command.AddCommand(diffSettings(cli, out, clusters))
+	return command
 }
 
-type cluster struct {
-	Routing          *routing `json:"routing,omitempty"`
-	MaxShardsPerNode string   `json:"max_shards_per_node,omitempty"`
+func getSettings(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		s               = ClusterSettings{Client: cli}
+		includeDefaults bool
+		flat            bool
+		command         = &cobra.Command{
+			Use:               "get",
+			Short:             "get cluster settings",
+			Long:              "get cluster settings ... wordless",
+			Args:              cobra.NoArgs,
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				res, err := s.get(includeDefaults, flat)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	f := command.Flags()
+	f.BoolVar(&includeDefaults, "include-defaults", false, "to show the default value of every setting too.")
+	f.BoolVar(&flat, "flat", false, "to show settings as dotted keys.")
+	return command
 }
 
-type script struct {
-	MaxCompilationsRate string `json:"max_compilations_rate,omitempty"`
+func setSettings(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		s         = ClusterSettings{Client: cli}
+		transient bool
+		command   = &cobra.Command{
+			Use:               "set key=value ...",
+			Short:             "set cluster settings, a value of null resets it",
+			Long:              "set cluster settings ... wordless",
+			Args:              cobra.MinimumNArgs(1),
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				values, err := parseSettings(args)
+				if err != nil {
+					return err
+				}
+				res, err := s.put(values, transient)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	command.Flags().BoolVar(&transient, "transient", false, "to set transient settings, which are lost on a full cluster restart.")
+	return command
 }
 
-type indices struct {
-	Breaker  *breaker  `json:"breaker,omitempty"`
-	Recovery *recovery `json:"recovery,omitempty"`
+func resetSettings(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		s         = ClusterSettings{Client: cli}
+		transient bool
+		command   = &cobra.Command{
+			Use:               "reset key ...",
+			Short:             "reset cluster settings to their default",
+			Long:              "reset cluster settings ... wordless",
+			Args:              cobra.MinimumNArgs(1),
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				values := make(map[string]interface{})
+				for _, key := range args {
+					values[key] = nil
+				}
+				res, err := s.put(values, transient)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, nil)
+			},
+		}
+	)
+	command.Flags().BoolVar(&transient, "transient", false, "to reset transient settings.")
+	return command
 }
 
-type recovery struct {
-	MaxBytesPerSec string `json:"max_bytes_per_sec,omitempty"`
+func diffSettings(cli *elasticsearch.Client, out io.Writer, clusters clusterClient) *cobra.Command {
+	var (
+		filename string
+		command  = &cobra.Command{
+			Use:   "diff [cluster] [cluster]",
+			Short: "diff the settings of two clusters, or of a cluster and a file",
+			Long:  "diff cluster settings ... wordless",
+			Args:  cobra.MaximumNArgs(2),
+			ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) >= 2 {
+					return nil, cobra.ShellCompDirectiveNoFileComp
+				}
+				return es.CompleteConfigEnv(toComplete), cobra.ShellCompDirectiveNoFileComp
+			},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var sources []settingsSource
+				for _, name := range args {
+					sources = append(sources, clusterSource(name, clusters))
+				}
+				if filename != "" {
+					sources = append(sources, fileSource(filename))
+				}
+				if len(sources) == 1 {
+					current, _ := es.CurrentCluster()
+					sources = append([]settingsSource{currentSource(current, cli)}, sources...)
+				}
+				if len(sources) != 2 {
+					return errors.New("diff needs two clusters, or a cluster and --file")
+				}
+				left, err := sources[0].load()
+				if err != nil {
+					return err
+				}
+				right, err := sources[1].load()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "--- %s\n+++ %s\n", sources[0].name, sources[1].name)
+				if !printSettingsDiff(out, left, right) {
+					fmt.Fprintln(out, "no differences")
+				}
+				return nil
+			},
+		}
+	)
+	command.Flags().StringVarP(&filename, "file", "f", "", "compare with the settings of a json or yaml file, as shown by 'settings get'.")
+	return command
 }
 
-type routing struct {
-	Allocation *allocation `json:"allocation,omitempty"`
+type ClusterSettings struct {
+	Client *elasticsearch.Client
 }
 
-type allocation struct {
-	ClusterConcurrentRebalanced    string `json:"cluster_concurrent_rebalance,omitempty"`
-	NodeConcurrentRecoveries       string `json:"node_concurrent_recoveries,omitempty"`
-	NodeInitialPrimariesRecoveries string `json:"node_initial_primaries_recoveries,omitempty"`
-	Disk                           *disk  `json:"disk,omitempty"`
-	Enable                         string `json:"enable,omitempty"`
+func (s *ClusterSettings) get(includeDefaults, flat bool) (*esapi.Response, error) {
+	return s.Client.Cluster.GetSettings(
+		s.Client.Cluster.GetSettings.WithIncludeDefaults(includeDefaults),
+		s.Client.Cluster.GetSettings.WithFlatSettings(flat),
+		s.Client.Cluster.GetSettings.WithPretty())
 }
 
-type disk struct {
-	Watermark *watermark `json:"watermark,omitempty"`
+func (s *ClusterSettings) put(values map[string]interface{}, transient bool) (*esapi.Response, error) {
+	data, err := settingsBody(values, transient)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Client.Cluster.PutSettings(bytes.NewReader(data), s.Client.Cluster.PutSettings.WithPretty())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed when sending put request")
+	}
+	return res, nil
 }
 
-type watermark struct {
-	High string `json:"high,omitempty"`
-	Low  string `json:"low,omitempty"`
+// flat returns the persistent and transient settings as dotted keys, like persistent.cluster.max_shards_per_node.
+func (s *ClusterSettings) flat() (map[string]interface{}, error) {
+	res, err := s.Client.Cluster.GetSettings(s.Client.Cluster.GetSettings.WithFlatSettings(true))
+	if err != nil {
+		return nil, err
+	}
+	if err := es.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSettings(body)
 }
 
-type breaker struct {
-	Fielddata *fielddata `json:"fielddata,omitempty"`
-	Request   *request   `json:"request,omitempty"`
-	Total     *total     `json:"total,omitempty"`
+type settingsSource struct {
+	name string
+	load func() (map[string]interface{}, error)
 }
 
-type fielddata struct {
-	Limit string `json:"limit,omitempty"`
+func currentSource(name string, cli *elasticsearch.Client) settingsSource {
+	return settingsSource{name: name, load: (&ClusterSettings{Client: cli}).flat}
 }
 
-type request struct {
-	Limit string `json:"limit,omitempty"`
+func clusterSource(name string, clusters clusterClient) settingsSource {
+	return settingsSource{name: name, load: func() (map[string]interface{}, error) {
+		cli, err := clusters(name)
+		if err != nil {
+			return nil, err
+		}
+		return (&ClusterSettings{Client: cli}).flat()
+	}}
 }
 
-type total struct {
-	Limit string `json:"limit,omitempty"`
+func fileSource(filename string) settingsSource {
+	return settingsSource{name: filename, load: func() (map[string]interface{}, error) {
+		body, err := es.DecodeFromFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return decodeSettings(body)
+	}}
 }
 
-func (S *Settings) WithAllocationSettings(ClusterConcurrentRebalanced, NodeConcurrentRecoveries, NodeInitialPrimariesRecoveries, Enable string) *Settings {
-	if len(ClusterConcurrentRebalanced) == 0 && len(NodeConcurrentRecoveries) == 0 && len(NodeInitialPrimariesRecoveries) == 0 && len(Enable) == 0 {
-		return S
+// decodeSettings flattens a settings document, a document without
+// persistent or transient sections is taken as persistent settings.
+func decodeSettings(body []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "settings are not a json object")
 	}
-	if S.Persistent.Cluster != nil && S.Persistent.Cluster.Routing != nil {
-		if S.Persistent.Cluster.Routing.Allocation != nil {
-			S.Persistent.Cluster.Routing.Allocation.NodeInitialPrimariesRecoveries = NodeInitialPrimariesRecoveries
-			S.Persistent.Cluster.Routing.Allocation.ClusterConcurrentRebalanced = ClusterConcurrentRebalanced
-			S.Persistent.Cluster.Routing.Allocation.NodeConcurrentRecoveries = NodeConcurrentRecoveries
-			S.Persistent.Cluster.Routing.Allocation.Enable = Enable
-		} else {
-			S.Persistent.Cluster.Routing.Allocation = &allocation{
-				ClusterConcurrentRebalanced:    ClusterConcurrentRebalanced,
-				NodeConcurrentRecoveries:       NodeConcurrentRecoveries,
-				NodeInitialPrimariesRecoveries: NodeInitialPrimariesRecoveries,
-				Enable:                         Enable,
-			}
-		}
-	} else {
-		S.Persistent.Cluster = &cluster{
-			Routing: &routing{
-				Allocation: &allocation{
-					ClusterConcurrentRebalanced:    ClusterConcurrentRebalanced,
-					NodeConcurrentRecoveries:       NodeConcurrentRecoveries,
-					NodeInitialPrimariesRecoveries: NodeInitialPrimariesRecoveries,
-					Enable:                         Enable,
-				},
-			},
-		}
+	_, p := doc[persistentSettings]
+	_, t := doc[transientSettings]
+	if !p && !t && len(doc) != 0 {
+		doc = map[string]interface{}{persistentSettings: doc}
 	}
-	return S
+	// defaults are not set on purpose, they only show up with --include-defaults
+	delete(doc, "defaults")
+	flat := make(map[string]interface{})
+	flattenSettings("", doc, flat)
+	return flat, nil
 }
 
-func (S *Settings) WithBreakerFielddata(BreakerFielddata string) *Settings {
-	if len(BreakerFielddata) == 0 {
-		return S
-	}
-
-	if S.Persistent.Indices != nil {
-		S.Persistent.Indices.Breaker.Fielddata = &fielddata{
-			Limit: BreakerFielddata,
-		}
-	} else {
-		S.Persistent.Indices = &indices{
-			Breaker: &breaker{
-				Fielddata: &fielddata{
-					Limit: BreakerFielddata,
-				},
-			},
+// parseSettings parses key=value arguments, a value of null resets the setting.
+func parseSettings(args []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("bad setting %q, want key=value", arg)
 		}
+		values[kv[0]] = settingValue(kv[1])
 	}
-
-	return S
+	return values, nil
 }
 
-func (S *Settings) WithBreakerRequest(BreakerRequest string) *Settings {
-	if len(BreakerRequest) == 0 {
-		return S
-	}
-
-	if S.Persistent.Indices != nil {
-		S.Persistent.Indices.Breaker.Request = &request{
-			Limit: BreakerRequest,
-		}
-	} else {
-		S.Persistent.Indices = &indices{
-			Breaker: &breaker{
-				Request: &request{
-					Limit: BreakerRequest,
-				},
-			},
-		}
+func settingValue(v string) interface{} {
+	if v == "null" {
+		return nil
 	}
-
-	return S
+	return v
 }
 
-func (S *Settings) WithBreakerTotal(BreakerTotal string) *Settings {
-	if len(BreakerTotal) == 0 {
-		return S
+// settingsBody builds a put settings request from dotted keys.
+func settingsBody(values map[string]interface{}, transient bool) ([]byte, error) {
+	tree, err := settingsTree(values)
+	if err != nil {
+		return nil, err
 	}
-
-	if S.Persistent.Indices != nil {
-		S.Persistent.Indices.Breaker.Total = &total{
-			Limit: BreakerTotal,
-		}
-	} else {
-		S.Persistent.Indices = &indices{
-			Breaker: &breaker{
-				Total: &total{
-					Limit: BreakerTotal,
-				},
-			},
-		}
+	scope := persistentSettings
+	if transient {
+		scope = transientSettings
 	}
-
-	return S
+	data, err := json.Marshal(map[string]interface{}{scope: tree})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to Marshal settings")
+	}
+	return data, nil
 }
 
-func (S *Settings) WithWatermark(High, Low string) *Settings {
-	if len(High) == 0 && len(Low) == 0 {
-		return S
+// settingsTree nests dotted keys, cluster.routing.allocation.enable=primaries
+// becomes {"cluster":{"routing":{"allocation":{"enable":"primaries"}}}}.
+func settingsTree(values map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
 	}
-	if S.Persistent.Cluster != nil && S.Persistent.Cluster.Routing != nil {
-		S.Persistent.Cluster.Routing.Allocation.Disk = &disk{
-			Watermark: &watermark{
-				Low:  Low,
-				High: High,
-			},
-		}
-	} else {
-		S.Persistent.Cluster = &cluster{
-			Routing: &routing{
-				Allocation: &allocation{
-					Disk: &disk{
-						Watermark: &watermark{
-							Low:  Low,
-							High: High,
-						},
-					},
-				},
-			},
+	sort.Strings(keys)
+	tree := make(map[string]interface{})
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		node := tree
+		for i, part := range parts {
+			if part == "" {
+				return nil, errors.Errorf("bad setting %q, empty key", key)
+			}
+			if i == len(parts)-1 {
+				if _, ok := node[part]; ok {
+					return nil, errors.Errorf("setting %q conflicts with a setting below it", key)
+				}
+				node[part] = values[key]
+				break
+			}
+			next, ok := node[part]
+			if !ok {
+				child := make(map[string]interface{})
+				node[part] = child
+				node = child
+				continue
+			}
+			child, ok := next.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("setting %q conflicts with %q", key, strings.Join(parts[:i+1], "."))
+			}
+			node = child
 		}
 	}
-	return S
+	return tree, nil
 }
 
-func (S *Settings) WithRecovery(MaxBytesPerSec string) *Settings {
-	if len(MaxBytesPerSec) == 0 {
-		return S
+// flattenSettings is the reverse of settingsTree, keys which are dotted already are kept as they are.
+func flattenSettings(prefix string, v interface{}, flat map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		flat[prefix] = v
+		return
 	}
-	if S.Persistent.Indices != nil {
-		S.Persistent.Indices.Recovery = &recovery{
-			MaxBytesPerSec: MaxBytesPerSec,
-		}
-	} else {
-		S.Persistent.Indices = &indices{
-			Recovery: &recovery{
-				MaxBytesPerSec: MaxBytesPerSec,
-			},
+	for k, child := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
 		}
+		flattenSettings(key, child, flat)
 	}
-
-	return S
 }
 
-func (S *Settings) WithMaxShardsPerNode(MaxShardsPerNode string) *Settings {
-	if len(MaxShardsPerNode) == 0 {
-		return S
+// printSettingsDiff prints the settings changed, removed and added from left to right,
+// it reports whether there was any difference.
+func printSettingsDiff(out io.Writer, left, right map[string]interface{}) bool {
+	keys := make([]string, 0, len(left)+len(right))
+	for k := range left {
+		keys = append(keys, k)
 	}
-	if S.Persistent.Cluster != nil {
-		S.Persistent.Cluster.MaxShardsPerNode = MaxShardsPerNode
-	} else {
-		S.Persistent.Cluster = &cluster{
-			MaxShardsPerNode: MaxShardsPerNode,
+	for k := range right {
+		if _, ok := left[k]; !ok {
+			keys = append(keys, k)
 		}
 	}
-
-	return S
-}
-
-func (S *Settings) WithMaxCompilationsRate(MaxCompilationsRate string) *Settings {
-	if len(MaxCompilationsRate) == 0 {
-		return S
+	sort.Strings(keys)
+	changed := false
+	for _, k := range keys {
+		l, inLeft := left[k]
+		r, inRight := right[k]
+		switch {
+		case !inRight:
+			fmt.Fprintf(out, "- %s: %s\n", k, settingString(l))
+		case !inLeft:
+			fmt.Fprintf(out, "+ %s: %s\n", k, settingString(r))
+		case settingString(l) != settingString(r):
+			fmt.Fprintf(out, "~ %s: %s -> %s\n", k, settingString(l), settingString(r))
+		default:
+			continue
+		}
+		changed = true
 	}
-
-	S.Persistent.Script = &script{MaxCompilationsRate: MaxCompilationsRate}
-
-	return S
+	return changed
 }
 
-func NewSettings() *Settings {
-	return &Settings{Persistent: &persistent{}}
+func settingString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
 }
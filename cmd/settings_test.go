@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/toughnoah/blackbean/pkg/fake"
@@ -8,422 +11,115 @@ import (
 
 var _ = Describe("put settings test", func() {
 
-	Context("test withFunc.", func() {
-		It("test WithAllocationSettings", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{Persistent: &persistent{
-						Cluster: &cluster{
-							Routing: &routing{
-								Allocation: &allocation{
-									ClusterConcurrentRebalanced:    "1",
-									NodeConcurrentRecoveries:       "2",
-									NodeInitialPrimariesRecoveries: "",
-									Enable:                         "",
-									Disk:                           nil,
+	Context("test settings tree.", func() {
+		It("test settingsTree", func() {
+			tree, err := settingsTree(map[string]interface{}{
+				"cluster.routing.allocation.enable":              nil,
+				"cluster.routing.allocation.disk.watermark.low":  "85%",
+				"cluster.routing.allocation.disk.watermark.high": "90%",
+				"indices.breaker.fielddata.limit":                "60%",
+				"cluster.max_shards_per_node":                    "1000",
+			})
+			Expect(err).To(BeNil())
+			Expect(tree).To(Equal(map[string]interface{}{
+				"cluster": map[string]interface{}{
+					"max_shards_per_node": "1000",
+					"routing": map[string]interface{}{
+						"allocation": map[string]interface{}{
+							"enable": nil,
+							"disk": map[string]interface{}{
+								"watermark": map[string]interface{}{
+									"low":  "85%",
+									"high": "90%",
 								},
 							},
 						},
 					},
-					},
 				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								Routing: &routing{
-									Allocation: &allocation{
-										Disk: &disk{
-											Watermark: &watermark{
-												Low: "87%",
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{Persistent: &persistent{
-						Cluster: &cluster{
-							Routing: &routing{
-								Allocation: &allocation{
-									ClusterConcurrentRebalanced:    "1",
-									NodeConcurrentRecoveries:       "2",
-									NodeInitialPrimariesRecoveries: "",
-									Enable:                         "",
-									Disk: &disk{
-										Watermark: &watermark{
-											Low: "87%",
-										},
-									},
-								},
-							},
-						},
-					},
+				"indices": map[string]interface{}{
+					"breaker": map[string]interface{}{
+						"fielddata": map[string]interface{}{"limit": "60%"},
 					},
 				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithAllocationSettings("1", "2", "", "")
-				Expect(settings).To(Equal(tc.want))
-			}
-			settings := NewSettings()
-			settings.WithAllocationSettings("", "", "", "")
-			Expect(settings).To(Equal(settings))
+			}))
+
+			_, err = settingsTree(map[string]interface{}{"cluster.routing": "x", "cluster.routing.allocation.enable": "all"})
+			Expect(err).To(MatchError(`setting "cluster.routing.allocation.enable" conflicts with "cluster.routing"`))
+			_, err = settingsTree(map[string]interface{}{"cluster..enable": "all"})
+			Expect(err).To(MatchError(`bad setting "cluster..enable", empty key`))
 		})
-		It("test WithBreakerTotal", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-								},
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Request: &request{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-									Request: &request{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithBreakerTotal("80%")
-				Expect(settings).To(Equal(tc.want))
-			}
-			settings := NewSettings()
-			settings.WithBreakerTotal("")
-			Expect(settings).To(Equal(settings))
+		It("test settingsBody", func() {
+			data, err := settingsBody(map[string]interface{}{"script.max_compilations_rate": "180/1m"}, false)
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal(`{"persistent":{"script":{"max_compilations_rate":"180/1m"}}}`))
+			data, err = settingsBody(map[string]interface{}{"cluster.routing.allocation.enable": nil}, true)
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal(`{"transient":{"cluster":{"routing":{"allocation":{"enable":null}}}}}`))
 		})
-		It("test WithBreakerRequest", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Request: &request{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-									Request: &request{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithBreakerRequest("60%")
-				Expect(settings).To(Equal(tc.want))
-			}
-			settings := NewSettings()
-			settings.WithBreakerRequest("")
-			Expect(settings).To(Equal(settings))
+		It("test parseSettings", func() {
+			values, err := parseSettings([]string{"indices.recovery.max_bytes_per_sec=100mb", "cluster.routing.allocation.enable=null", "a.b=c=d"})
+			Expect(err).To(BeNil())
+			Expect(values).To(Equal(map[string]interface{}{
+				"indices.recovery.max_bytes_per_sec": "100mb",
+				"cluster.routing.allocation.enable":  nil,
+				"a.b":                                "c=d",
+			}))
+			_, err = parseSettings([]string{"cluster.routing.allocation.enable"})
+			Expect(err).To(MatchError(`bad setting "cluster.routing.allocation.enable", want key=value`))
 		})
-		It("test WithBreakerFielddata", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Fielddata: &fielddata{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "80%",
-									},
-									Fielddata: &fielddata{
-										Limit: "60%",
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithBreakerFielddata("60%")
-				Expect(settings).To(Equal(tc.want))
-			}
-			settings := NewSettings()
-			settings.WithBreakerFielddata("")
-			Expect(settings).To(Equal(settings))
+		It("test decodeSettings", func() {
+			flat, err := decodeSettings([]byte(`{"persistent":{"cluster.routing.allocation.enable":"primaries","indices":{"breaker":{"total":{"limit":"70%"}}}},"transient":{}}`))
+			Expect(err).To(BeNil())
+			Expect(flat).To(Equal(map[string]interface{}{
+				"persistent.cluster.routing.allocation.enable": "primaries",
+				"persistent.indices.breaker.total.limit":       "70%",
+			}))
+			flat, err = decodeSettings([]byte(`{"cluster":{"max_shards_per_node":"1000"}}`))
+			Expect(err).To(BeNil())
+			Expect(flat).To(Equal(map[string]interface{}{
+				"persistent.cluster.max_shards_per_node": "1000",
+			}))
 		})
-		It("test WithWatermark", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								Routing: &routing{
-									Allocation: &allocation{
-										Disk: &disk{
-											Watermark: &watermark{
-												Low:  "80%",
-												High: "85%",
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								Routing: &routing{
-									Allocation: &allocation{
-										Enable: "null",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								Routing: &routing{
-									Allocation: &allocation{
-										Disk: &disk{
-											Watermark: &watermark{
-												Low:  "80%",
-												High: "85%",
-											},
-										},
-										Enable: "null",
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithWatermark("85%", "80%")
-				Expect(settings).To(Equal(tc.want))
-			}
-			settings := NewSettings()
-			settings.WithWatermark("", "")
-			Expect(settings).To(Equal(settings))
+		It("test printSettingsDiff", func() {
+			buf := new(bytes.Buffer)
+			changed := printSettingsDiff(buf, map[string]interface{}{
+				"persistent.a": "1",
+				"persistent.b": "2",
+				"persistent.c": json.Number("3"),
+			}, map[string]interface{}{
+				"persistent.a": "1",
+				"persistent.b": "4",
+				"transient.d":  []interface{}{"x", "y"},
+			})
+			Expect(changed).To(BeTrue())
+			Expect(buf.String()).To(Equal("~ persistent.b: 2 -> 4\n- persistent.c: 3\n+ transient.d: [\"x\",\"y\"]\n"))
+			Expect(printSettingsDiff(buf, map[string]interface{}{"a": "1"}, map[string]interface{}{"a": "1"})).To(BeFalse())
 		})
-		It("test WithRecovery", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Recovery: &recovery{
-									MaxBytesPerSec: "1000",
-								},
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "85%",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Indices: &indices{
-								Breaker: &breaker{
-									Total: &total{
-										Limit: "85%",
-									},
-								},
-								Recovery: &recovery{
-									MaxBytesPerSec: "1000",
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithRecovery("1000")
-				Expect(settings).To(Equal(tc.want))
+	})
+
+	Context("test settings commands", func() {
+		It("test get set reset", func() {
+			mockTr := &fake.MockEsResponse{
+				ResponseString: `{"acknowledged":true}`,
 			}
-			settings := NewSettings()
-			settings.WithRecovery("")
-			Expect(settings).To(Equal(settings))
+			_, err := executeCommand("settings get --include-defaults --flat", mockTr)
+			Expect(err).To(BeNil())
+			_, err = executeCommand("settings set cluster.routing.allocation.enable=primaries --transient", mockTr)
+			Expect(err).To(BeNil())
+			_, err = executeCommand("settings reset cluster.routing.allocation.enable", mockTr)
+			Expect(err).To(BeNil())
+			_, err = executeCommand("settings set cluster.routing.allocation.enable", mockTr)
+			Expect(err).To(MatchError(`bad setting "cluster.routing.allocation.enable", want key=value`))
 		})
-		It("test WithMaxShardsPerNode", func() {
-			testCases := []struct {
-				settings *Settings
-				want     *Settings
-			}{
-				{
-					settings: NewSettings(),
-					want: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								MaxShardsPerNode: "10000",
-							},
-						},
-					},
-				},
-				{
-					settings: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								Routing: &routing{
-									Allocation: &allocation{
-										ClusterConcurrentRebalanced: "1",
-									},
-								},
-							},
-						},
-					},
-					want: &Settings{
-						Persistent: &persistent{
-							Cluster: &cluster{
-								MaxShardsPerNode: "10000",
-								Routing: &routing{
-									Allocation: &allocation{
-										ClusterConcurrentRebalanced: "1",
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			for _, tc := range testCases {
-				settings := tc.settings
-				settings.WithMaxShardsPerNode("10000")
-				Expect(settings).To(Equal(tc.want))
+		It("test diff with file", func() {
+			mockTr := &fake.MockEsResponse{
+				ResponseString: `{"persistent":{"cluster.max_shards_per_node":"1000"},"transient":{}}`,
 			}
-			settings := NewSettings()
-			settings.WithMaxShardsPerNode("")
-			Expect(settings).To(Equal(settings))
-		})
-		It("test WithMaxCompilationsRate", func() {
-			settings := NewSettings()
-			settings.WithMaxCompilationsRate("180/1m")
-			want := &Settings{Persistent: &persistent{
-				Script: &script{
-					MaxCompilationsRate: "180/1m",
-				},
-			}}
-			Expect(settings).To(Equal(want))
-			settings = NewSettings()
-			settings.WithMaxCompilationsRate("")
-			Expect(settings).To(Equal(settings))
+			o, err := executeCommand("settings diff -f ../pkg/testdata/settings.yaml", mockTr)
+			Expect(err).To(BeNil())
+			Expect(o).To(ContainSubstring("~ persistent.cluster.max_shards_per_node: 1000 -> 2000\n+ persistent.cluster.routing.allocation.enable: primaries\n"))
+			_, err = executeCommand("settings diff", mockTr)
+			Expect(err).To(MatchError("diff needs two clusters, or a cluster and --file"))
 		})
 	})
 
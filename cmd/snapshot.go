@@ -20,7 +20,7 @@ func snapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	command.AddCommand(restoreSnapshot(cli, out))
 	command.AddCommand(createSnapshot(cli, out))
 	command.AddCommand(deleteSnapshot(cli, out))
-	command.AddCommand(getSnapshot(cli, out))
+	command.AddCommand(watchable(getSnapshot(cli, out)))
 	return command
 }
 
@@ -36,10 +36,10 @@ func createSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := so.createSnapshot(repository, args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -59,6 +59,7 @@ func deleteSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		so         = Snapshot{client: cli}
 		repository string
+		yes        bool
 		command    = &cobra.Command{
 			Use:   "delete [snapshot]",
 			Short: "delete specific snapshots ",
@@ -71,11 +72,14 @@ func deleteSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				return so.getRepoAllSnapshotsForFlag(repository), cobra.ShellCompDirectiveNoFileComp
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := confirmDestructive(out, yes, "delete snapshot", func() []string { return []string{repository + "/" + args[0]} }); err != nil {
+					return err
+				}
 				res, err := so.deleteSnapshot(repository, args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -88,6 +92,7 @@ func deleteSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 		log.Fatal(err)
 	}
 	_ = command.MarkFlagRequired("repo")
+	addYesFlag(command, &yes)
 	return command
 }
 
@@ -108,10 +113,10 @@ func getSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := so.getSnapshot(repository, args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, snapshotLayout)
 			},
 		}
 	)
@@ -147,10 +152,10 @@ func restoreSnapshot(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := so.recoverIndices(args[0], snapshots, index, renamePattern, renameReplacement)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -6,9 +6,11 @@ import (
 	"github.com/mattn/go-shellwords"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
 	"github.com/toughnoah/blackbean/pkg/es"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -38,6 +40,15 @@ var _ = AfterSuite(func() {
 })
 
 func executeCommand(cmdToExecute string, MockTransport http.RoundTripper) (string, error) {
+	return executeCommandWithInfo(cmdToExecute, MockTransport, &es.ClusterInfo{
+		Username: TestUsername,
+		Password: TestPassword,
+		Url:      TestUrl,
+	})
+}
+
+// executeCommandWithInfo runs the command against a profile of the given cluster info.
+func executeCommandWithInfo(cmdToExecute string, MockTransport http.RoundTripper, ci *es.ClusterInfo) (string, error) {
 	defer monkey.Unpatch(es.GetProfile)
 	defer monkey.Unpatch(InitConfig)
 	args, err := shellwords.Parse(cmdToExecute)
@@ -45,18 +56,17 @@ func executeCommand(cmdToExecute string, MockTransport http.RoundTripper) (strin
 		return "", err
 	}
 	buf := new(bytes.Buffer)
-	ci := &es.ClusterInfo{
-		Username: TestUsername,
-		Password: TestPassword,
-		Url:      TestUrl,
-	}
 	monkey.Patch(es.GetProfile, func() (profile *es.Profile, err error) {
 		p := &es.Profile{
 			ClusterInfo: ci,
 		}
 		return p, nil
 	})
-	monkey.Patch(InitConfig, func() {})
+	monkey.Patch(InitConfig, func() error { return nil })
+	if viper.GetString(es.AuditLogSpec) == "" {
+		// keep the audit trail of tests out of the home of whoever runs them
+		viper.Set(es.AuditLogSpec, filepath.Join(os.TempDir(), "blackbean-test-audit.log"))
+	}
 	file, _ := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
 	fd := int(file.Fd())
 	fakeTerminal := &MockTerminal{
@@ -67,9 +77,7 @@ func executeCommand(cmdToExecute string, MockTransport http.RoundTripper) (strin
 	root.SetErr(buf)
 	root.SetOut(buf)
 	root.SetArgs(args)
-	if err = root.Execute(); err != nil {
-		return "", err
-	}
-	out := buf.String()
-	return out, nil
+	// what was printed before failing, like a summary, is returned along with the error
+	err = root.Execute()
+	return buf.String(), err
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/spf13/cobra"
+	"io"
+)
+
+func task(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		command = &cobra.Command{
+			Use:               "task [subcommand]",
+			Short:             "task operations",
+			Long:              "task operations ... wordless",
+			Args:              cobra.NoArgs,
+			ValidArgsFunction: noCompletions,
+		}
+	)
+	command.AddCommand(watchable(listTasks(cli, out)))
+	return command
+}
+
+func listTasks(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
+	var (
+		t        = Task{Client: cli}
+		actions  []string
+		nodes    []string
+		detailed bool
+		command  = &cobra.Command{
+			Use:               "list",
+			Short:             "list tasks running in the cluster",
+			Long:              "list tasks running in the cluster ... wordless",
+			Args:              cobra.NoArgs,
+			ValidArgsFunction: noCompletions,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				res, err := t.list(actions, nodes, detailed)
+				if err != nil {
+					return err
+				}
+				return printResponse(out, res, taskLayout)
+			},
+		}
+	)
+	f := command.Flags()
+	f.StringSliceVar(&actions, "actions", nil, "comma separated actions to list, wildcards allowed, like '*reindex'.")
+	f.StringSliceVar(&nodes, "nodes", nil, "comma separated node ids or names to list the tasks of.")
+	f.BoolVar(&detailed, "detailed", false, "to show the description of each task.")
+	return command
+}
+
+type Task struct {
+	Client *elasticsearch.Client
+}
+
+func (t *Task) list(actions, nodes []string, detailed bool) (*esapi.Response, error) {
+	return t.Client.Tasks.List(
+		t.Client.Tasks.List.WithActions(actions...),
+		t.Client.Tasks.List.WithNodes(nodes...),
+		t.Client.Tasks.List.WithDetailed(detailed),
+		t.Client.Tasks.List.WithGroupBy("none"),
+		t.Client.Tasks.List.WithPretty(),
+	)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+	"testing"
+)
+
+func TestListTasks(t *testing.T) {
+	mock := &fake.MockEsResponse{
+		ResponseString: `{"tasks":[{"node":"n1","id":12,"type":"transport","action":"indices:data/write/reindex","cancellable":true}]}`,
+	}
+	out, err := executeCommand("task list --actions '*reindex' --detailed", mock)
+	require.NoError(t, err)
+	require.Equal(t, "[200 OK] "+mock.ResponseString+"\n", out)
+
+	out, err = executeCommand("task list -o table", mock)
+	require.NoError(t, err)
+	require.Equal(t, "NODE   ID   ACTION                       CANCELLABLE\nn1     12   indices:data/write/reindex   true\n", out)
+}
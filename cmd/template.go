@@ -3,7 +3,6 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/spf13/cobra"
@@ -48,10 +47,10 @@ func getTemplate(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				} else {
 					res, err = t.getIndexTemplate(args[0])
 				}
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, templateLayout)
 			},
 		}
 	)
@@ -78,10 +77,10 @@ func applyTemplate(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 					return es.NoRawRequestFlagError()
 				}
 				res, err := t.applyIndexTemplate(args[0], req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -105,10 +104,10 @@ func deleteTemplate(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := t.deleteIndexTemplate(args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
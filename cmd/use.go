@@ -10,9 +10,12 @@ import (
 	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 )
 
+const ConfigFileMode = 0600
+
 func useCluster(out io.Writer) *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "use [cluster]",
@@ -39,15 +42,10 @@ type Modify struct {
 }
 
 func (m *Modify) ModifyCurrentCluster(cluster string) error {
-	var blackbeanConfig map[string]interface{}
 	path := m.GetConfig()
-	file, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
-	}
-	err = yaml.Unmarshal(file, &blackbeanConfig)
-	if err != nil {
-		return err
+	blackbeanConfig := m.ReadConfigFile(path)
+	if m.err != nil {
+		return m.err
 	}
 	checked := m.CheckClusterConfigExists(cluster)
 	if !checked {
@@ -64,14 +62,44 @@ func (m *Modify) ModifyConfigFile(cluster, path string, config map[string]interf
 		return
 	}
 	config[es.CurrentSpec] = cluster
+	m.WriteConfigFile(path, config)
+}
+
+// ReadConfigFile reads the whole .blackbean file, keeping keys blackbean does not know about.
+func (m *Modify) ReadConfigFile(path string) (config map[string]interface{}) {
+	if m.err != nil {
+		return
+	}
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		m.err = err
+		return
+	}
+	if m.err = yaml.Unmarshal(file, &config); m.err != nil {
+		return
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	return
+}
+
+// WriteConfigFile writes config back to path, readable by its owner only since it holds credentials.
+func (m *Modify) WriteConfigFile(path string, config map[string]interface{}) {
+	if m.err != nil {
+		return
+	}
 	bytesFile, err := yaml.Marshal(config)
 	if err != nil {
 		m.err = err
 		return
 	}
-	if err = ioutil.WriteFile(path, bytesFile, 0755); err != nil {
+	if err = ioutil.WriteFile(path, bytesFile, ConfigFileMode); err != nil {
 		m.err = err
+		return
 	}
+	// WriteFile keeps the mode of an existing file
+	m.err = os.Chmod(path, ConfigFileMode)
 }
 func (m *Modify) GetConfig() (path string) {
 	if cfgFile != "" {
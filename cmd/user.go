@@ -10,7 +10,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/toughnoah/blackbean/pkg/es"
-	"golang.org/x/term"
 	"io"
 	"strings"
 )
@@ -61,10 +60,10 @@ func getUser(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				} else {
 					res, err = u.getUser(args[0])
 				}
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, userLayout)
 			},
 		}
 	)
@@ -88,10 +87,10 @@ func createUser(cli *elasticsearch.Client, out io.Writer, in io.ReadWriter, fd i
 			RunE: func(cmd *cobra.Command, args []string) error {
 				u.Username = args[0]
 				res, err := u.createUser(req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -129,10 +128,10 @@ func updateUser(cli *elasticsearch.Client, out io.Writer, in io.ReadWriter, fd i
 				}
 				u.Username = args[0]
 				res, err := u.updateUser(req)
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -149,6 +148,7 @@ func updateUser(cli *elasticsearch.Client, out io.Writer, in io.ReadWriter, fd i
 func deleteUser(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 	var (
 		u       = &User{Client: cli}
+		yes     bool
 		command = &cobra.Command{
 			Use:   "delete [user]",
 			Short: "delete specify user",
@@ -161,14 +161,18 @@ func deleteUser(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 				return u.getAllUser(), cobra.ShellCompDirectiveNoFileComp
 			},
 			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := confirmDestructive(out, yes, "delete user", func() []string { return []string{args[0]} }); err != nil {
+					return err
+				}
 				res, err := u.deleteUser(args[0])
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
+	addYesFlag(command, &yes)
 	return command
 }
 
@@ -240,27 +244,15 @@ func (u *User) getUser(user string) (*esapi.Response, error) {
 }
 
 func (u *User) getPasswordFromTerminal() (pwd string, err error) {
-	oldState, err := term.MakeRaw(u.Fd)
-	if err != nil {
-		return
-	}
-	ss := term.NewTerminal(u.In, "> ")
-	pwd, err = ss.ReadPassword("password: ")
-	if err != nil {
-		return
-	}
-	confirmPwd, err := ss.ReadPassword("confirm password: ")
+	pwds, err := es.ReadPasswordFromTerminal(u.In, u.Fd, "password: ", "confirm password: ")
 	if err != nil {
 		return
 	}
-	if pwd != confirmPwd {
+	if pwds[0] != pwds[1] {
 		err = errors.New("two input password must be consistent")
 		return
 	}
-	if err = term.Restore(u.Fd, oldState); err != nil {
-		return
-	}
-	return
+	return pwds[0], nil
 }
 
 type createUserBody struct {
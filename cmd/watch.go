@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	watchableAnnotation = "blackbean/watchable"
+
+	clearScreen  = "\033[H\033[2J"
+	highlightOn  = "\033[7m"
+	highlightOff = "\033[0m"
+)
+
+var (
+	watch         bool
+	watchInterval time.Duration
+	// cellOrSpace splits a line into cells and the spaces between them
+	cellOrSpace = regexp.MustCompile(`\s+|\S+`)
+)
+
+// watchSignals stops a watch, on Ctrl-C by default.
+var watchSignals = func() (<-chan os.Signal, func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	return ch, func() { signal.Stop(ch) }
+}
+
+// watchable marks a read-only command, which --watch may re-run.
+func watchable(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[watchableAnnotation] = "true"
+	return cmd
+}
+
+func isWatchable(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[watchableAnnotation]
+	return ok
+}
+
+// screen is the writer handed to every command, so that --watch can
+// capture the output of a refresh and redraw it as a whole.
+type screen struct {
+	out   io.Writer
+	frame *bytes.Buffer
+}
+
+func newScreen(out io.Writer) *screen {
+	return &screen{out: out}
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	if s.frame != nil {
+		return s.frame.Write(p)
+	}
+	return s.out.Write(p)
+}
+
+func (s *screen) capture(run func() error) (string, error) {
+	s.frame = new(bytes.Buffer)
+	defer func() { s.frame = nil }()
+	err := run()
+	return s.frame.String(), err
+}
+
+type refresh struct {
+	text string
+	err  error
+}
+
+// startWatch replaces the RunE of cmd with one re-running it every --interval.
+func startWatch(cmd *cobra.Command, s *screen) error {
+	if !isWatchable(cmd) {
+		return errors.Errorf("--watch is only supported by read-only commands, not %q", cmd.CommandPath())
+	}
+	if watchInterval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		stop, cancel := watchSignals()
+		defer cancel()
+		var last []string
+		for {
+			started := time.Now()
+			// a refresh runs aside, so that Ctrl-C works while a slow request is pending
+			done := make(chan refresh, 1)
+			go func() {
+				text, err := s.capture(func() error { return run(cmd, args) })
+				done <- refresh{text: text, err: err}
+			}()
+			select {
+			case <-stop:
+				// let the pending refresh finish, so that it never writes over what
+				// comes next, a second Ctrl-C is no longer caught and kills it
+				cancel()
+				<-done
+				return nil
+			case r := <-done:
+				if r.err != nil {
+					// keep watching, the cluster may just be recovering
+					r.text += "Error: " + r.err.Error() + "\n"
+				}
+				lines := strings.Split(strings.TrimRight(r.text, "\n"), "\n")
+				header := fmt.Sprintf("Every %s: %s    %s (took %s)", watchInterval,
+					strings.TrimSpace(cmd.CommandPath()+" "+strings.Join(args, " ")),
+					started.Format("2006-01-02 15:04:05"), time.Since(started).Round(time.Millisecond))
+				s.draw(header, lines, last)
+				last = lines
+			}
+			// the interval starts when a refresh is done, a slow link never piles up requests
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(watchInterval):
+			}
+		}
+	}
+	return nil
+}
+
+func (s *screen) draw(header string, lines, last []string) {
+	fmt.Fprint(s.out, clearScreen)
+	fmt.Fprintln(s.out, header)
+	fmt.Fprintln(s.out)
+	for i, line := range lines {
+		if last == nil {
+			fmt.Fprintln(s.out, line)
+			continue
+		}
+		var before string
+		if i < len(last) {
+			before = last[i]
+		}
+		fmt.Fprintln(s.out, highlightChanges(line, before))
+	}
+}
+
+// highlightChanges highlights the cells of line which differ from the cell
+// at the same position of the line before.
+func highlightChanges(line, before string) string {
+	cells := strings.Fields(before)
+	var b strings.Builder
+	n := 0
+	for _, token := range cellOrSpace.FindAllString(line, -1) {
+		if strings.TrimSpace(token) == "" {
+			b.WriteString(token)
+			continue
+		}
+		if n >= len(cells) || cells[n] != token {
+			b.WriteString(highlightOn + token + highlightOff)
+		} else {
+			b.WriteString(token)
+		}
+		n++
+	}
+	return b.String()
+}
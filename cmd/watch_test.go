@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/toughnoah/blackbean/pkg/fake"
+)
+
+// stopWatchAfter stops --watch after d instead of waiting for Ctrl-C.
+func stopWatchAfter(t *testing.T, d time.Duration) {
+	origin := watchSignals
+	t.Cleanup(func() { watchSignals = origin })
+	watchSignals = func() (<-chan os.Signal, func()) {
+		ch := make(chan os.Signal, 1)
+		timer := time.AfterFunc(d, func() { ch <- os.Interrupt })
+		return ch, func() { timer.Stop() }
+	}
+}
+
+func TestHighlightChanges(t *testing.T) {
+	testCases := []struct {
+		name   string
+		line   string
+		before string
+		want   string
+	}{
+		{
+			name:   "nothing changed",
+			line:   "noah  green  3",
+			before: "noah  green  3",
+			want:   "noah  green  3",
+		},
+		{
+			name:   "a cell changed",
+			line:   "noah  yellow 3",
+			before: "noah  green  3",
+			want:   "noah  " + highlightOn + "yellow" + highlightOff + " 3",
+		},
+		{
+			name:   "a new line",
+			line:   "noah 3",
+			before: "",
+			want:   highlightOn + "noah" + highlightOff + " " + highlightOn + "3" + highlightOff,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, highlightChanges(tc.line, tc.before))
+		})
+	}
+}
+
+func TestStartWatch(t *testing.T) {
+	watchInterval = 10 * time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+
+	require.EqualError(t, startWatch(&cobra.Command{Use: "delete"}, newScreen(new(bytes.Buffer))),
+		`--watch is only supported by read-only commands, not "delete"`)
+
+	stopWatchAfter(t, 100*time.Millisecond)
+	out := new(bytes.Buffer)
+	s := newScreen(out)
+	refreshes := 0
+	cmd := watchable(&cobra.Command{
+		Use: "health",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			refreshes++
+			_, err := s.Write([]byte("green " + strings.Repeat("x", refreshes) + "\n"))
+			return err
+		},
+	})
+	require.NoError(t, startWatch(cmd, s))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Greater(t, refreshes, 1)
+	frames := strings.Split(out.String(), clearScreen)
+	require.Contains(t, frames[1], "Every 10ms: health")
+	require.Contains(t, frames[1], "\ngreen x\n")
+	require.Contains(t, frames[2], "\ngreen "+highlightOn+"xx"+highlightOff+"\n")
+}
+
+func TestStopWatchWaitsForRefresh(t *testing.T) {
+	watchInterval = 10 * time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	stopWatchAfter(t, 20*time.Millisecond)
+	out := new(bytes.Buffer)
+	s := newScreen(out)
+	var finished int32
+	cmd := watchable(&cobra.Command{
+		Use: "health",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			time.Sleep(100 * time.Millisecond)
+			_, err := s.Write([]byte("green\n"))
+			atomic.StoreInt32(&finished, 1)
+			return err
+		},
+	})
+	require.NoError(t, startWatch(cmd, s))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Equal(t, int32(1), atomic.LoadInt32(&finished))
+	require.Nil(t, s.frame)
+	require.Empty(t, out.String(), "the refresh stopped is not drawn")
+}
+
+func TestWatchCommand(t *testing.T) {
+	stopWatchAfter(t, 100*time.Millisecond)
+	mock := &fake.MockEsResponse{ResponseString: `[{"status":"green"}]`}
+	out, err := executeCommand("cat health -o name -w --interval 10ms", mock)
+	require.NoError(t, err)
+	require.Contains(t, out, clearScreen)
+	require.Contains(t, out, "\ngreen\n")
+
+	_, err = executeCommand("index delete noah -w", mock)
+	require.EqualError(t, err, `--watch is only supported by read-only commands, not "blackbean index delete"`)
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/spf13/cobra"
@@ -35,10 +34,10 @@ func watcherStart(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := w.start()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return nil
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -56,10 +55,10 @@ func watcherStop(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := w.stop()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -76,10 +75,10 @@ func watcherStats(cli *elasticsearch.Client, out io.Writer) *cobra.Command {
 			ValidArgsFunction: noCompletions,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				res, err := w.stats()
-				if err == nil {
-					fmt.Fprintln(out, res)
+				if err != nil {
+					return err
 				}
-				return err
+				return printResponse(out, res, nil)
 			},
 		}
 	)
@@ -32,4 +32,10 @@ func TestWatcher(t *testing.T) {
 		require.NoError(t, err)
 	}
 
+	failed := &fake.MockEsResponse{
+		ResponseString: `{"error":"watcher is not available","status":500}`,
+		StatusCode:     500,
+	}
+	_, err := executeCommand("watcher start", failed)
+	require.EqualError(t, err, "[500 Internal Server Error] watcher is not available")
 }
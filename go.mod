@@ -11,6 +11,7 @@ require (
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.13.0
 	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/prashantv/gostub v1.0.0
 	github.com/spf13/afero v1.6.0
 	github.com/spf13/cobra v1.1.3
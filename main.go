@@ -16,20 +16,17 @@ limitations under the License.
 package main
 
 import (
-	"crypto/tls"
-	"github.com/spf13/cobra"
 	"github.com/toughnoah/blackbean/cmd"
-	"net/http"
+	"github.com/toughnoah/blackbean/pkg/es"
 	"os"
 )
 
 func main() {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
 	args := os.Args[1:]
-	rootCmd := cmd.NewRootCmd(transport, os.Stdout, os.Stdin, int(os.Stdin.Fd()), args)
-	cobra.CheckErr(rootCmd.Execute())
+	// transport is built per cluster profile from its tls settings
+	rootCmd := cmd.NewRootCmd(nil, os.Stdout, os.Stdin, int(os.Stdin.Fd()), args)
+	// cobra already printed the error, exit by its kind so scripts can branch on it
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(es.ExitCode(err))
+	}
 }
@@ -0,0 +1,179 @@
+// Package audit keeps a local trail of the requests blackbean sends to change a cluster.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/toughnoah/blackbean/pkg/es"
+)
+
+// DefaultPath is where the audit log goes unless 'audit_log' is set in .blackbean.
+const DefaultPath = "~/.blackbean/audit.log"
+
+// Record is one line of the audit log.
+type Record struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Cluster string    `json:"cluster"`
+	Command string    `json:"command"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	// BodySHA256 tells bodies apart without keeping passwords or documents in the log.
+	BodySHA256 string `json:"body_sha256,omitempty"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Log appends records as json lines to a file, it is safe for concurrent use.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLog returns the log at path, which may start with ~.
+func NewLog(path string) (*Log, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad audit log path")
+	}
+	return &Log{path: expanded}, nil
+}
+
+// Path is the expanded path of the log.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Write appends a record, creating the log readable by its owner only.
+func (l *Log) Write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return errors.Wrap(err, "failed to create audit log dir")
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit log")
+	}
+	defer f.Close()
+	// one write per line, so that concurrent blackbean processes do not interleave
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Filter selects records, zero values select everything.
+type Filter struct {
+	Since   time.Time
+	Cluster string
+}
+
+func (f Filter) match(r Record) bool {
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	return f.Cluster == "" || f.Cluster == r.Cluster
+}
+
+// Read returns the records matching f, oldest first. A missing log has no records.
+func (l *Log) Read(f Filter) ([]Record, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit log")
+	}
+	defer file.Close()
+	return read(file, f)
+}
+
+func read(in io.Reader, f Filter) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, errors.Wrapf(err, "bad audit log line %d", n)
+		}
+		if f.match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// CurrentUser is the name of the os user running blackbean.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+type transport struct {
+	next http.RoundTripper
+	log  *Log
+	base Record
+	now  func() time.Time
+}
+
+// NewTransport wraps next, recording every request but the reads of es.IsReadRequest to log.
+// base carries the user, cluster and command of the records.
+func NewTransport(next http.RoundTripper, log *Log, base Record) http.RoundTripper {
+	return &transport{next: next, log: log, base: base, now: time.Now}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if es.IsReadRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+	r := t.base
+	r.Time = t.now()
+	r.Method = req.Method
+	r.Path = req.URL.Path
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(body)
+		r.BodySHA256 = hex.EncodeToString(sum[:])
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Status = res.StatusCode
+	}
+	if werr := t.log.Write(r); werr != nil {
+		// a broken trail must not break the cluster operation
+		log.Printf("failed to write audit log: %s", werr)
+	}
+	return res, err
+}
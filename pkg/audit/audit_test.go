@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method == http.MethodPut {
+			// the body still reaches es after being hashed
+			require.Equal(t, `{"persistent":{}}`, string(body))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := NewLog(filepath.Join(dir, "nested", "audit.log"))
+	require.NoError(t, err)
+	now := time.Date(2021, 6, 22, 3, 0, 0, 0, time.UTC)
+	tr := NewTransport(http.DefaultTransport, l, Record{User: "noah", Cluster: "prod", Command: "blackbean settings set a=b"}).(*transport)
+	tr.now = func() time.Time { return now }
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		path := "/_cluster/settings"
+		if method == http.MethodPost {
+			path = "/noah/_search"
+		}
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(`{"persistent":{}}`))
+		require.NoError(t, err)
+		res, err := tr.RoundTrip(req)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	req, err := http.NewRequest(http.MethodDelete, "http://127.0.0.1:1/noah", nil)
+	require.NoError(t, err)
+	_, err = tr.RoundTrip(req)
+	require.Error(t, err)
+
+	info, err := os.Stat(l.Path())
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	records, err := l.Read(Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	sum := sha256.Sum256([]byte(`{"persistent":{}}`))
+	require.Equal(t, Record{
+		Time:       now,
+		User:       "noah",
+		Cluster:    "prod",
+		Command:    "blackbean settings set a=b",
+		Method:     http.MethodPut,
+		Path:       "/_cluster/settings",
+		BodySHA256: hex.EncodeToString(sum[:]),
+		Status:     http.StatusCreated,
+	}, records[0])
+	require.Equal(t, http.MethodDelete, records[1].Method)
+	require.Equal(t, records[0].BodySHA256, records[1].BodySHA256)
+	require.Equal(t, 0, records[2].Status)
+	require.NotEmpty(t, records[2].Error)
+}
+
+func TestRead(t *testing.T) {
+	log := `{"time":"2021-06-20T03:00:00Z","user":"noah","cluster":"prod","method":"PUT","path":"/_cluster/settings","status":200}
+
+{"time":"2021-06-22T03:00:00Z","user":"noah","cluster":"qa","method":"DELETE","path":"/noah","status":200}
+{"time":"2021-06-22T04:00:00Z","user":"noah","cluster":"prod","method":"DELETE","path":"/noah","status":404}
+`
+	testCases := []struct {
+		name   string
+		filter Filter
+		paths  []string
+	}{
+		{name: "all", paths: []string{"/_cluster/settings", "/noah", "/noah"}},
+		{name: "cluster", filter: Filter{Cluster: "prod"}, paths: []string{"/_cluster/settings", "/noah"}},
+		{name: "since", filter: Filter{Since: time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC)}, paths: []string{"/noah", "/noah"}},
+		{name: "both", filter: Filter{Cluster: "prod", Since: time.Date(2021, 6, 21, 0, 0, 0, 0, time.UTC)}, paths: []string{"/noah"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := read(strings.NewReader(log), tc.filter)
+			require.NoError(t, err)
+			var paths []string
+			for _, r := range records {
+				paths = append(paths, r.Path)
+			}
+			require.Equal(t, tc.paths, paths)
+		})
+	}
+	_, err := read(strings.NewReader("{\n"), Filter{})
+	require.EqualError(t, err, "bad audit log line 1: unexpected end of JSON input")
+
+	l, err := NewLog(filepath.Join(os.TempDir(), "blackbean-no-such-audit.log"))
+	require.NoError(t, err)
+	records, err := l.Read(Filter{})
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
@@ -12,7 +12,6 @@ import (
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -27,32 +26,111 @@ const (
 
 	ConfigPassword = "password"
 
+	ConfigPasswordEnv = "password_env"
+
 	ConfigUrl = "url"
 
+	ConfigCloudID = "cloud_id"
+
+	ConfigAPIKey = "api_key"
+
+	ConfigServiceToken = "service_token"
+
+	OverrideSpec = "override-cluster"
+
+	ClusterEnv = "BLACKBEAN_CLUSTER"
+
+	AuditLogSpec = "audit_log"
+
 	EmptyData = "{}"
 
 	EmptyFile = ""
 )
 
 func GetProfile() (*Profile, error) {
-	profile := &Profile{}
+	return handleProfile(&rootHandler, &Profile{})
+}
+
+// GetClusterProfile resolves the profile of a named cluster, whichever is current.
+func GetClusterProfile(name string) (*Profile, error) {
+	return handleProfile(clusterHandler, &Profile{env: name})
+}
+
+func handleProfile(first Handler, profile *Profile) (*Profile, error) {
 	rootHandler.handler = clusterHandler
 	clusterHandler.handler = infoHandle
-	rootHandler.Handle(profile)
+	infoHandle.handler = passwordHandle
+	passwordHandle.handler = tlsHandler
+	first.Handle(profile)
 	if profile.handleErr != nil {
 		return nil, profile.handleErr
 	}
 	return profile, nil
 }
 
+// CurrentCluster returns the cluster chosen by --cluster or BLACKBEAN_CLUSTER,
+// falling back to the 'current' spec of .blackbean.
+func CurrentCluster() (string, bool) {
+	if override := viper.GetString(OverrideSpec); override != "" {
+		return override, true
+	}
+	current, ok := viper.Get(CurrentSpec).(string)
+	return current, ok
+}
+
+// NewLazyClient returns a client whose requests fail until InitLazyClient wires it
+// to a cluster, so that commands can be built before any cluster profile is read.
+func NewLazyClient() *elasticsearch.Client {
+	cli, _ := elasticsearch.NewClient(elasticsearch.Config{
+		Transport:    notReadyTransport{},
+		DisableRetry: true,
+	})
+	return cli
+}
+
+// InitLazyClient points every holder of lazy at the cluster of cli.
+func InitLazyClient(lazy, cli *elasticsearch.Client) {
+	*lazy.API = *cli.API
+	lazy.Transport = cli.Transport
+}
+
+type notReadyTransport struct{}
+
+func (notReadyTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("es client is not initialized, no cluster profile loaded")
+}
+
 func NewEsClient(url, username, password string, transport http.RoundTripper) (*elasticsearch.Client, error) {
+	return NewEsClientFromInfo(&ClusterInfo{Url: url, Username: username, Password: password}, transport)
+}
+
+// NewEsClientFromInfo creates a client from a cluster profile, using whichever
+// of basic auth, api key or service token the profile is configured with,
+// and its node addresses, sniffing and retry policy.
+func NewEsClientFromInfo(ci *ClusterInfo, transport http.RoundTripper) (*elasticsearch.Client, error) {
+	backoff, err := ci.Backoff()
+	if err != nil {
+		return nil, err
+	}
+	if ci.ReadOnly {
+		transport = NewReadOnlyTransport(transport)
+	}
 	cfg := elasticsearch.Config{
-		Transport: transport,
-		Addresses: []string{
-			url,
-		},
-		Username: username,
-		Password: password,
+		Transport:            transport,
+		Addresses:            ci.Addresses(),
+		CloudID:              ci.CloudID,
+		Username:             ci.Username,
+		Password:             ci.Password,
+		APIKey:               ci.APIKey,
+		ServiceToken:         ci.ServiceToken,
+		DiscoverNodesOnStart: ci.DiscoverNodes,
+		RetryOnStatus:        ci.RetryOnStatus,
+		RetryBackoff:         backoff,
+	}
+	// an unset max_retries keeps the client default, 0 turns retries off
+	if ci.MaxRetries != nil {
+		cfg.MaxRetries = *ci.MaxRetries
+		cfg.DisableRetry = *ci.MaxRetries == 0
 	}
 	es, err := elasticsearch.NewClient(cfg)
 	if err != nil {
@@ -63,10 +141,10 @@ func NewEsClient(url, username, password string, transport http.RoundTripper) (*
 
 func CompleteConfigEnv(toComplete string) []string {
 	var envArray []string
-	if viper.Get(ConfigSpec) == nil {
-		log.Fatal("error reading config file for shell completion")
+	cfg, ok := viper.Get(ConfigSpec).(map[string]interface{})
+	if !ok {
+		return nil
 	}
-	cfg := viper.Get(ConfigSpec).(map[string]interface{})
 	for env := range cfg {
 		if strings.HasPrefix(env, toComplete) {
 			envArray = append(envArray, env)
@@ -118,6 +196,38 @@ func DecodeFromFile(filename string) ([]byte, error) {
 	return *raw, nil
 }
 
+// DecodeAllFromFile decodes every yaml document or json value of filename,
+// a top level list giving its entries.
+func DecodeAllFromFile(filename string) ([]json.RawMessage, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := transform.NewReader(f, unicode.BOMOverride(unicode.UTF8.NewDecoder()))
+	d := util.NewYAMLOrJSONDecoder(reader, 4096)
+	var entries []json.RawMessage
+	for {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			// an empty yaml document, as before the first ---
+			continue
+		}
+		var list []json.RawMessage
+		if json.Unmarshal(raw, &list) == nil {
+			entries = append(entries, list...)
+			continue
+		}
+		entries = append(entries, raw)
+	}
+}
+
 type RequestBody struct {
 	Filename string
 	Data     string
@@ -11,6 +11,9 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 )
 
@@ -231,3 +234,145 @@ func TestGetFlagValue(t *testing.T) {
 	cmd.Flags().StringVar(&test, "test", "1", "for test")
 	require.Equal(t, GetFlagValue(cmd, "test"), "1")
 }
+
+func TestGetProfileWithClusterOverride(t *testing.T) {
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader(yamlExampleForShellCompletion)))
+	defer viper.Set(OverrideSpec, "")
+
+	viper.Set(OverrideSpec, "prd")
+	cluster, ok := CurrentCluster()
+	require.True(t, ok)
+	require.Equal(t, "prd", cluster)
+	profile, err := GetProfile()
+	require.NoError(t, err)
+	require.Equal(t, "https://a.es.com", profile.ClusterInfo.Url)
+
+	viper.Set(OverrideSpec, "nosuch")
+	_, err = GetProfile()
+	require.EqualError(t, err, `no cluster named "nosuch" in .blackbean`)
+
+	viper.Set(OverrideSpec, "")
+	cluster, _ = CurrentCluster()
+	require.Equal(t, "prod", cluster)
+}
+
+func TestGetClusterProfile(t *testing.T) {
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader(yamlExampleForShellCompletion)))
+
+	profile, err := GetClusterProfile("prd")
+	require.NoError(t, err)
+	require.Equal(t, "prd", profile.Name())
+	require.Equal(t, "https://a.es.com", profile.ClusterInfo.Url)
+	cluster, _ := CurrentCluster()
+	require.Equal(t, "prod", cluster)
+
+	_, err = GetClusterProfile("nosuch")
+	require.EqualError(t, err, `no cluster named "nosuch" in .blackbean`)
+}
+
+func TestNewEsClientFromInfoReadOnly(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	defer server.Close()
+
+	cli, err := NewEsClientFromInfo(&ClusterInfo{Url: server.URL, APIKey: "key", ReadOnly: true}, nil)
+	require.NoError(t, err)
+	res, err := cli.Cat.Health()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	_, err = cli.Indices.Delete([]string{"noah"})
+	require.EqualError(t, err, "read only profile refused DELETE /noah, only GET, HEAD and POST to _search, _msearch, _count, _cluster/allocation/explain, _pit, _search/scroll are sent")
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestNewEsClientFromInfoRetry(t *testing.T) {
+	var badHits, goodHits int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"cluster_name":"blackbean"}`))
+	}))
+	defer good.Close()
+
+	cli, err := NewEsClientFromInfo(&ClusterInfo{
+		Urls:          []string{bad.URL, good.URL},
+		Username:      "blackbean",
+		Password:      "bulldog",
+		MaxRetries:    intPtr(2),
+		RetryOnStatus: []int{http.StatusServiceUnavailable},
+		RetryBackoff:  "1ms",
+	}, nil)
+	require.NoError(t, err)
+	res, err := cli.Info()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, int32(1), atomic.LoadInt32(&badHits))
+	require.Equal(t, int32(1), atomic.LoadInt32(&goodHits))
+
+	// only the failing node, every retry is spent on it
+	atomic.StoreInt32(&badHits, 0)
+	cli, err = NewEsClientFromInfo(&ClusterInfo{
+		Urls:          []string{bad.URL},
+		APIKey:        "key",
+		MaxRetries:    intPtr(2),
+		RetryOnStatus: []int{http.StatusServiceUnavailable},
+		RetryBackoff:  "1ms",
+	}, nil)
+	require.NoError(t, err)
+	res, err = cli.Info()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.GreaterOrEqual(t, atomic.LoadInt32(&badHits), int32(2))
+
+	// max_retries 0 sends every request once
+	atomic.StoreInt32(&badHits, 0)
+	cli, err = NewEsClientFromInfo(&ClusterInfo{
+		Urls:          []string{bad.URL, good.URL},
+		APIKey:        "key",
+		MaxRetries:    intPtr(0),
+		RetryOnStatus: []int{http.StatusServiceUnavailable},
+		RetryBackoff:  "1ms",
+	}, nil)
+	require.NoError(t, err)
+	res, err = cli.Info()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.Equal(t, int32(1), atomic.LoadInt32(&badHits))
+
+	_, err = NewEsClientFromInfo(&ClusterInfo{Url: good.URL, APIKey: "key", RetryBackoff: "soon"}, nil)
+	require.Error(t, err)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestDecodeAllFromFile(t *testing.T) {
+	entries, err := DecodeAllFromFile("../testdata/bulk.yaml")
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	require.JSONEq(t, `{"delete":{"_index":"noah","_id":"2"}}`, string(entries[1]))
+	require.JSONEq(t, `{"update":{"_index":"noah","_id":"1"},"doc":{"age":4},"doc_as_upsert":true}`, string(entries[3]))
+
+	entries, err = DecodeAllFromFile("../testdata/msearch.json")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	entries, err = DecodeAllFromFile("../testdata/query.json")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	_, err = DecodeAllFromFile("../testdata/missing.yaml")
+	require.Error(t, err)
+}
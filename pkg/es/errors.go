@@ -0,0 +1,119 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+)
+
+// exit codes of blackbean, non 2xx responses exit by their status class
+// so that scripts can tell a missing index from a broken cluster.
+const (
+	ExitCodeError       = 1
+	ExitCodeRedirect    = 3
+	ExitCodeClientError = 4
+	ExitCodeServerError = 5
+)
+
+// ErrorCause is one cause of an es error, as in its "root_cause" list.
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index,omitempty"`
+}
+
+// ResponseError is a non 2xx es response.
+type ResponseError struct {
+	StatusCode int
+	ErrorCause
+	RootCause []ErrorCause
+	// Body is kept when the response is not an es error document.
+	Body string
+}
+
+func (e *ResponseError) Error() string {
+	status := fmt.Sprintf("[%d %s]", e.StatusCode, http.StatusText(e.StatusCode))
+	switch {
+	case e.Type == "" && e.Reason != "":
+		return status + " " + e.Reason
+	case e.Type == "" && e.Body != "":
+		return status + " " + e.Body
+	case e.Type == "":
+		return status
+	}
+	msg := fmt.Sprintf("%s %s: %s", status, e.Type, e.Reason)
+	for _, c := range e.RootCause {
+		if c.Type == e.Type && c.Reason == e.Reason {
+			continue
+		}
+		msg += fmt.Sprintf(", caused by %s: %s", c.Type, c.Reason)
+	}
+	return msg
+}
+
+// ExitCode is the exit code of the status class of the response.
+func (e *ResponseError) ExitCode() int {
+	switch {
+	case e.StatusCode >= 500:
+		return ExitCodeServerError
+	case e.StatusCode >= 400:
+		return ExitCodeClientError
+	case e.StatusCode >= 300:
+		return ExitCodeRedirect
+	}
+	return ExitCodeError
+}
+
+// CheckResponse returns a *ResponseError for a non 2xx response, consuming its body.
+func CheckResponse(res *esapi.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+	e := &ResponseError{StatusCode: res.StatusCode}
+	if res.Body == nil {
+		return e
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return e
+	}
+	var doc struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &doc); err == nil && len(doc.Error) != 0 {
+		var cause struct {
+			ErrorCause
+			RootCause []ErrorCause `json:"root_cause"`
+		}
+		// some apis report the error as a plain string
+		if json.Unmarshal(doc.Error, &cause) == nil {
+			e.ErrorCause, e.RootCause = cause.ErrorCause, cause.RootCause
+			return e
+		}
+		var reason string
+		if json.Unmarshal(doc.Error, &reason) == nil {
+			e.Reason = reason
+			return e
+		}
+	}
+	e.Body = strings.TrimSpace(string(body))
+	return e
+}
+
+// ExitCode maps an error returned by a command to the exit code of blackbean.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e *ResponseError
+	if errors.As(err, &e) {
+		return e.ExitCode()
+	}
+	return ExitCodeError
+}
@@ -0,0 +1,72 @@
+package es
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckResponse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		body     string
+		want     string
+		exitCode int
+	}{
+		{
+			name:   "success",
+			status: 200,
+			body:   `{"acknowledged":true}`,
+		},
+		{
+			name:     "es error with root cause",
+			status:   400,
+			body:     `{"error":{"root_cause":[{"type":"parse_exception","reason":"unknown key [foo]"}],"type":"x_content_parse_exception","reason":"[1:9] [query] failed to parse"},"status":400}`,
+			want:     "[400 Bad Request] x_content_parse_exception: [1:9] [query] failed to parse, caused by parse_exception: unknown key [foo]",
+			exitCode: ExitCodeClientError,
+		},
+		{
+			name:     "error as a string",
+			status:   503,
+			body:     `{"error":"no master","status":503}`,
+			want:     "[503 Service Unavailable] no master",
+			exitCode: ExitCodeServerError,
+		},
+		{
+			name:     "not an es error document",
+			status:   404,
+			body:     `{}`,
+			want:     "[404 Not Found] {}",
+			exitCode: ExitCodeClientError,
+		},
+		{
+			name:     "empty body",
+			status:   404,
+			want:     "[404 Not Found]",
+			exitCode: ExitCodeClientError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := &esapi.Response{StatusCode: tc.status, Body: ioutil.NopCloser(strings.NewReader(tc.body))}
+			err := CheckResponse(res)
+			if tc.want == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.want)
+			require.Equal(t, tc.exitCode, ExitCode(errors.Wrap(err, "wrapped")))
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	require.Equal(t, 0, ExitCode(nil))
+	require.Equal(t, ExitCodeError, ExitCode(errors.New("boom")))
+	require.Equal(t, ExitCodeRedirect, ExitCode(&ResponseError{StatusCode: 301}))
+}
@@ -1,18 +1,25 @@
 package es
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v2"
+	"golang.org/x/term"
+	"io"
 )
 
 var (
 	rootHandler            = RootHandler{}
 	clusterHandler         = &ClusterHandler{}
 	infoHandle             = &InfoHandler{}
+	passwordHandle         = &PasswordHandler{}
+	tlsHandler             = &TLSHandler{}
 	_              Handler = &RootHandler{}
 	_              Handler = &ClusterHandler{}
 	_              Handler = &InfoHandler{}
+	_              Handler = &PasswordHandler{}
+	_              Handler = &TLSHandler{}
 )
 
 type Handler interface {
@@ -22,15 +29,39 @@ type Handler interface {
 
 type Profile struct {
 	ClusterInfo *ClusterInfo
+	TLSConfig   *tls.Config
 	env         string
 	raw         interface{}
 	handleErr   error
 }
 
+// Name returns the name of the cluster profile in .blackbean.
+func (p *Profile) Name() string {
+	return p.env
+}
+
 type ClusterInfo struct {
-	Url      string `yaml:"url"`
-	Password string `yaml:"password"`
-	Username string `yaml:"username"`
+	Url                string   `yaml:"url,omitempty"`
+	Urls               []string `yaml:"urls,omitempty"`
+	CloudID            string   `yaml:"cloud_id,omitempty"`
+	Password           string   `yaml:"password,omitempty"`
+	Username           string   `yaml:"username,omitempty"`
+	PasswordEnv        string   `yaml:"password_env,omitempty"`
+	PasswordFile       string   `yaml:"password_file,omitempty"`
+	PasswordCommand    string   `yaml:"password_command,omitempty"`
+	APIKey             string   `yaml:"api_key,omitempty"`
+	ServiceToken       string   `yaml:"service_token,omitempty"`
+	CACert             string   `yaml:"ca_cert,omitempty"`
+	ClientCert         string   `yaml:"client_cert,omitempty"`
+	ClientKey          string   `yaml:"client_key,omitempty"`
+	ServerName         string   `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty"`
+	DiscoverNodes      bool     `yaml:"discover_nodes,omitempty"`
+	MaxRetries         *int     `yaml:"max_retries,omitempty"`
+	RetryOnStatus      []int    `yaml:"retry_on_status,omitempty"`
+	RetryBackoff       string   `yaml:"retry_backoff,omitempty"`
+	Protected          bool     `yaml:"protected,omitempty"`
+	ReadOnly           bool     `yaml:"read_only,omitempty"`
 }
 
 type ClusterHandler struct {
@@ -43,11 +74,11 @@ type RootHandler struct {
 
 func (r *RootHandler) Handle(profile *Profile) {
 	var ok bool
-	if viper.Get(CurrentSpec) == nil || viper.Get(ConfigSpec) == nil {
+	if viper.Get(ConfigSpec) == nil || (viper.Get(CurrentSpec) == nil && viper.GetString(OverrideSpec) == "") {
 		profile.handleErr = errors.New("can not read 'current/cluster' spec from .blackbean")
 		return
 	}
-	profile.env, ok = viper.Get(CurrentSpec).(string)
+	profile.env, ok = CurrentCluster()
 	if !ok {
 		profile.handleErr = errors.New("bad 'current' type from .blackbean, want string")
 		return
@@ -67,7 +98,10 @@ func (c *ClusterHandler) Handle(profile *Profile) {
 		profile.handleErr = errors.New("can not read 'cluster' from .blackbean")
 		return
 	}
-	profile.raw = cluster[profile.env]
+	if profile.raw, ok = cluster[profile.env]; !ok {
+		profile.handleErr = fmt.Errorf("no cluster named %q in .blackbean", profile.env)
+		return
+	}
 	c.next(profile)
 }
 
@@ -82,15 +116,13 @@ type InfoHandler struct {
 }
 
 func (i *InfoHandler) Handle(profile *Profile) {
-	ci := &ClusterInfo{}
-	marshal, err := yaml.Marshal(profile.raw)
+	ci, err := DecodeClusterInfo(profile.raw)
 	if err != nil {
 		profile.handleErr = err
 		return
 	}
-	err = yaml.Unmarshal(marshal, ci)
-	if err != nil {
-		profile.handleErr = err
+	if err = ci.Validate(); err != nil {
+		profile.handleErr = fmt.Errorf("cluster profile %q: %v", profile.env, err)
 		return
 	}
 	profile.ClusterInfo = ci
@@ -102,3 +134,87 @@ func (i *InfoHandler) next(profile *Profile) {
 		i.handler.Handle(profile)
 	}
 }
+
+// SetTerminal sets the terminal used to prompt for a password no source resolves.
+func SetTerminal(in io.ReadWriter, fd int) {
+	passwordHandle.in = in
+	passwordHandle.fd = fd
+}
+
+// Prompt reads a line, echoed, from the terminal set by SetTerminal.
+func Prompt(prompt string) (string, error) {
+	p := passwordHandle
+	if p.in == nil || !term.IsTerminal(p.fd) {
+		return "", errors.New("no terminal to prompt on")
+	}
+	return ReadLineFromTerminal(p.in, p.fd, prompt)
+}
+
+// SetNoPrompt keeps the password handler from prompting or running password_command,
+// for shell completion which runs on every TAB.
+func SetNoPrompt(noPrompt bool) {
+	passwordHandle.noPrompt = noPrompt
+}
+
+type PasswordHandler struct {
+	handler  Handler
+	in       io.ReadWriter
+	fd       int
+	noPrompt bool
+}
+
+func (p *PasswordHandler) Handle(profile *Profile) {
+	ci := profile.ClusterInfo
+	if ci.Username == "" {
+		p.next(profile)
+		return
+	}
+	if p.noPrompt && ci.Password == "" && ci.PasswordEnv == "" && ci.PasswordFile == "" {
+		profile.handleErr = fmt.Errorf("cluster profile %q: no password stored", profile.env)
+		return
+	}
+	resolved, err := ci.ResolvePassword()
+	if err != nil {
+		profile.handleErr = fmt.Errorf("cluster profile %q: %v", profile.env, err)
+		return
+	}
+	if !resolved {
+		if p.noPrompt || p.in == nil || !term.IsTerminal(p.fd) {
+			profile.handleErr = fmt.Errorf("cluster profile %q: no password resolved, set one of password, password_env, password_file, password_command", profile.env)
+			return
+		}
+		pwds, err := ReadPasswordFromTerminal(p.in, p.fd, fmt.Sprintf("password of %s for cluster %s: ", ci.Username, profile.env))
+		if err != nil {
+			profile.handleErr = fmt.Errorf("cluster profile %q: %v", profile.env, err)
+			return
+		}
+		ci.Password = pwds[0]
+	}
+	p.next(profile)
+}
+
+func (p *PasswordHandler) next(profile *Profile) {
+	if p.handler != nil {
+		p.handler.Handle(profile)
+	}
+}
+
+type TLSHandler struct {
+	handler Handler
+}
+
+func (t *TLSHandler) Handle(profile *Profile) {
+	cfg, err := NewTLSConfig(profile.ClusterInfo)
+	if err != nil {
+		profile.handleErr = fmt.Errorf("cluster profile %q: %v", profile.env, err)
+		return
+	}
+	profile.TLSConfig = cfg
+	t.next(profile)
+}
+
+func (t *TLSHandler) next(profile *Profile) {
+	if t.handler != nil {
+		t.handler.Handle(profile)
+	}
+}
@@ -0,0 +1,137 @@
+package es
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RedactedSecret = "******"
+
+	maxRetryBackoff = 30 * time.Second
+)
+
+// SecretKeys are the cluster profile keys whose values must never be printed.
+var SecretKeys = []string{ConfigPassword, ConfigAPIKey, ConfigServiceToken}
+
+// ConfigKeys returns every key a cluster profile understands.
+func ConfigKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(ClusterInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// IsSecretKey reports whether the value of a cluster profile key must be redacted.
+func IsSecretKey(key string) bool {
+	return Check(key, SecretKeys)
+}
+
+// DecodeClusterInfo decodes a raw cluster profile read from .blackbean.
+func DecodeClusterInfo(raw interface{}) (*ClusterInfo, error) {
+	ci := &ClusterInfo{}
+	marshal, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(marshal, ci); err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+// Addresses returns 'url' followed by every entry of 'urls'.
+func (ci ClusterInfo) Addresses() []string {
+	var addresses []string
+	if ci.Url != "" {
+		addresses = append(addresses, ci.Url)
+	}
+	return append(addresses, ci.Urls...)
+}
+
+// Backoff returns the retry backoff of the profile, doubling 'retry_backoff'
+// on every attempt up to 30s. It is nil when 'retry_backoff' is not set.
+func (ci ClusterInfo) Backoff() (func(attempt int) time.Duration, error) {
+	if ci.RetryBackoff == "" {
+		return nil, nil
+	}
+	base, err := time.ParseDuration(ci.RetryBackoff)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad 'retry_backoff'")
+	}
+	return func(attempt int) time.Duration {
+		backoff := base
+		for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > maxRetryBackoff {
+			return maxRetryBackoff
+		}
+		return backoff
+	}, nil
+}
+
+// Validate checks that the profile has exactly one kind of address, url/urls or cloud_id,
+// at most one auth method among username/password, api_key and service_token,
+// and a sane retry policy.
+func (ci ClusterInfo) Validate() error {
+	if (len(ci.Addresses()) == 0) == (ci.CloudID == "") {
+		return errors.New("exactly one of 'url/urls' and 'cloud_id' must be set")
+	}
+	if ci.MaxRetries != nil && *ci.MaxRetries < 0 {
+		return errors.New("'max_retries' must not be negative")
+	}
+	if _, err := ci.Backoff(); err != nil {
+		return err
+	}
+	var methods []string
+	if ci.Username != "" || ci.hasPasswordSource() {
+		methods = append(methods, "username/password")
+	}
+	if ci.Username == "" && ci.hasPasswordSource() {
+		return errors.New("'username' is required along with a password")
+	}
+	if sources := ci.passwordSources(); len(sources) > 1 {
+		sort.Strings(sources)
+		return errors.Errorf("exactly one password source is allowed, got %s", strings.Join(sources, ", "))
+	}
+	if ci.APIKey != "" {
+		methods = append(methods, "api_key")
+	}
+	if ci.ServiceToken != "" {
+		methods = append(methods, "service_token")
+	}
+	// no auth at all is fine, for unsecured local or dev clusters
+	if len(methods) > 1 {
+		return errors.Errorf("at most one auth method is allowed, got %s", strings.Join(methods, ", "))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the profile with all secret values masked.
+func (ci ClusterInfo) Redacted() ClusterInfo {
+	for _, secret := range []*string{&ci.Password, &ci.APIKey, &ci.ServiceToken} {
+		if *secret != "" {
+			*secret = RedactedSecret
+		}
+	}
+	return ci
+}
+
+// String renders the profile as yaml with secrets redacted, so that printing
+// a ClusterInfo never leaks credentials.
+func (ci ClusterInfo) String() string {
+	out, err := yaml.Marshal(ci.Redacted())
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
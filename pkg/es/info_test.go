@@ -0,0 +1,109 @@
+package es
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClusterInfoValidate(t *testing.T) {
+	testCases := []struct {
+		name string
+		info ClusterInfo
+		err  string
+	}{
+		{
+			name: "basic auth",
+			info: ClusterInfo{Url: "https://a.es.com", Username: "a", Password: "b"},
+		},
+		{
+			name: "api key with cloud id",
+			info: ClusterInfo{CloudID: "prod:abc", APIKey: "key"},
+		},
+		{
+			name: "service token",
+			info: ClusterInfo{Url: "https://a.es.com", ServiceToken: "token"},
+		},
+		{
+			name: "no address",
+			info: ClusterInfo{APIKey: "key"},
+			err:  "exactly one of 'url/urls' and 'cloud_id' must be set",
+		},
+		{
+			name: "url and cloud id",
+			info: ClusterInfo{Url: "https://a.es.com", CloudID: "prod:abc", APIKey: "key"},
+			err:  "exactly one of 'url/urls' and 'cloud_id' must be set",
+		},
+		{
+			name: "urls and cloud id",
+			info: ClusterInfo{Urls: []string{"https://a.es.com"}, CloudID: "prod:abc", APIKey: "key"},
+			err:  "exactly one of 'url/urls' and 'cloud_id' must be set",
+		},
+		{
+			name: "negative retries",
+			info: ClusterInfo{Urls: []string{"https://a.es.com"}, APIKey: "key", MaxRetries: intPtr(-1)},
+			err:  "'max_retries' must not be negative",
+		},
+		{
+			name: "bad backoff",
+			info: ClusterInfo{Urls: []string{"https://a.es.com"}, APIKey: "key", RetryBackoff: "soon"},
+			err:  `bad 'retry_backoff': time: invalid duration "soon"`,
+		},
+		{
+			name: "no auth",
+			info: ClusterInfo{Url: "https://a.es.com"},
+		},
+		{
+			name: "two auth methods",
+			info: ClusterInfo{Url: "https://a.es.com", Username: "a", Password: "b", APIKey: "key"},
+			err:  "at most one auth method is allowed, got username/password, api_key",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.info.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestClusterInfoRedacted(t *testing.T) {
+	ci := &ClusterInfo{Url: "https://a.es.com", Username: "noah", Password: "bulldog", APIKey: "secret-key", ServiceToken: "secret-token"}
+	redacted := ci.Redacted()
+	require.Equal(t, RedactedSecret, redacted.Password)
+	require.Equal(t, RedactedSecret, redacted.APIKey)
+	require.Equal(t, RedactedSecret, redacted.ServiceToken)
+	require.Equal(t, "noah", redacted.Username)
+	require.Equal(t, "bulldog", ci.Password)
+
+	printed := fmt.Sprintf("%v %s", ci, *ci)
+	for _, secret := range []string{"bulldog", "secret-key", "secret-token"} {
+		require.False(t, strings.Contains(printed, secret))
+	}
+	require.Empty(t, ClusterInfo{Url: "https://a.es.com"}.Redacted().Password)
+}
+
+func TestClusterInfoAddresses(t *testing.T) {
+	ci := ClusterInfo{Url: "https://a.es.com", Urls: []string{"https://b.es.com", "https://c.es.com"}}
+	require.Equal(t, []string{"https://a.es.com", "https://b.es.com", "https://c.es.com"}, ci.Addresses())
+	require.Empty(t, ClusterInfo{CloudID: "prod:abc"}.Addresses())
+}
+
+func TestClusterInfoBackoff(t *testing.T) {
+	backoff, err := ClusterInfo{}.Backoff()
+	require.NoError(t, err)
+	require.Nil(t, backoff)
+
+	backoff, err = ClusterInfo{RetryBackoff: "100ms"}.Backoff()
+	require.NoError(t, err)
+	require.Equal(t, 100*time.Millisecond, backoff(1))
+	require.Equal(t, 200*time.Millisecond, backoff(2))
+	require.Equal(t, 400*time.Millisecond, backoff(3))
+	require.Equal(t, maxRetryBackoff, backoff(20))
+}
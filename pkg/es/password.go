@@ -0,0 +1,116 @@
+package es
+
+import (
+	"bytes"
+	"github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ReadPasswordFromTerminal reads one password per prompt from the terminal without echo.
+func ReadPasswordFromTerminal(in io.ReadWriter, fd int, prompts ...string) (pwds []string, err error) {
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if restoreErr := term.Restore(fd, oldState); err == nil {
+			err = restoreErr
+		}
+	}()
+	ss := term.NewTerminal(in, "> ")
+	for _, prompt := range prompts {
+		var pwd string
+		pwd, err = ss.ReadPassword(prompt)
+		if err != nil {
+			return nil, err
+		}
+		pwds = append(pwds, pwd)
+	}
+	return
+}
+
+// ReadLineFromTerminal reads one line, echoed, from the terminal.
+func ReadLineFromTerminal(in io.ReadWriter, fd int, prompt string) (line string, err error) {
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if restoreErr := term.Restore(fd, oldState); err == nil {
+			err = restoreErr
+		}
+	}()
+	return term.NewTerminal(in, prompt).ReadLine()
+}
+
+// hasPasswordSource reports whether any of password, password_env,
+// password_file and password_command is set.
+func (ci ClusterInfo) hasPasswordSource() bool {
+	return ci.Password != "" || ci.PasswordEnv != "" || ci.PasswordFile != "" || ci.PasswordCommand != ""
+}
+
+func (ci ClusterInfo) passwordSources() (sources []string) {
+	for key, value := range map[string]string{
+		ConfigPassword:     ci.Password,
+		ConfigPasswordEnv:  ci.PasswordEnv,
+		"password_file":    ci.PasswordFile,
+		"password_command": ci.PasswordCommand,
+	} {
+		if value != "" {
+			sources = append(sources, key)
+		}
+	}
+	return
+}
+
+// ResolvePassword fills the password of a basic auth profile from password_env,
+// password_file or password_command. It reports false if none of them yields a password.
+func (ci *ClusterInfo) ResolvePassword() (bool, error) {
+	switch {
+	case ci.Password != "":
+		return true, nil
+	case ci.PasswordEnv != "":
+		ci.Password = os.Getenv(ci.PasswordEnv)
+	case ci.PasswordFile != "":
+		raw, err := readFileExpandHome(ci.PasswordFile)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read password_file")
+		}
+		ci.Password = strings.TrimRight(string(raw), "\r\n")
+	case ci.PasswordCommand != "":
+		pwd, err := runPasswordCommand(ci.PasswordCommand)
+		if err != nil {
+			return false, errors.Wrapf(err, "password_command %q failed", ci.PasswordCommand)
+		}
+		ci.Password = pwd
+	}
+	return ci.Password != "", nil
+}
+
+func runPasswordCommand(command string) (string, error) {
+	args, err := shellwords.Parse(command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errors.New("empty command")
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err = c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrap(err, msg)
+		}
+		return "", err
+	}
+	// like pass, take the first line of the output
+	return strings.SplitN(stdout.String(), "\n", 2)[0], nil
+}
@@ -0,0 +1,134 @@
+package es
+
+import (
+	"bytes"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestResolvePassword(t *testing.T) {
+	require.NoError(t, os.Setenv("BLACKBEAN_TEST_PASSWORD", "bulldog"))
+	defer os.Unsetenv("BLACKBEAN_TEST_PASSWORD")
+	testCases := []struct {
+		name     string
+		info     ClusterInfo
+		resolved bool
+		err      bool
+	}{
+		{
+			name:     "plain password",
+			info:     ClusterInfo{Password: "bulldog"},
+			resolved: true,
+		},
+		{
+			name:     "password env",
+			info:     ClusterInfo{PasswordEnv: "BLACKBEAN_TEST_PASSWORD"},
+			resolved: true,
+		},
+		{
+			name: "unset password env",
+			info: ClusterInfo{PasswordEnv: "BLACKBEAN_TEST_NO_PASSWORD"},
+		},
+		{
+			name:     "password file",
+			info:     ClusterInfo{PasswordFile: "../testdata/password"},
+			resolved: true,
+		},
+		{
+			name: "missing password file",
+			info: ClusterInfo{PasswordFile: "../testdata/nosuch"},
+			err:  true,
+		},
+		{
+			name:     "password command",
+			info:     ClusterInfo{PasswordCommand: "echo 'bulldog'"},
+			resolved: true,
+		},
+		{
+			name: "failing password command",
+			info: ClusterInfo{PasswordCommand: "false"},
+			err:  true,
+		},
+		{
+			name: "nothing set",
+			info: ClusterInfo{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, err := tc.info.ResolvePassword()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.resolved, resolved)
+			if tc.resolved {
+				require.Equal(t, "bulldog", tc.info.Password)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordSources(t *testing.T) {
+	ci := ClusterInfo{Url: "https://a.es.com", Username: "noah", PasswordEnv: "PWD_ENV", PasswordFile: "pwd"}
+	require.EqualError(t, ci.Validate(), "exactly one password source is allowed, got password_env, password_file")
+	ci = ClusterInfo{Url: "https://a.es.com", PasswordEnv: "PWD_ENV"}
+	require.EqualError(t, ci.Validate(), "'username' is required along with a password")
+	ci = ClusterInfo{Url: "https://a.es.com", Username: "noah", PasswordCommand: "pass show es/prod"}
+	require.NoError(t, ci.Validate())
+}
+
+func TestPasswordHandler(t *testing.T) {
+	SetTerminal(nil, 0)
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader([]byte(`cluster:
+  prod:
+    url: https://a.es.com
+    username: noah
+    password_file: ../testdata/password
+  qa:
+    url: https://b.es.com
+    username: noah
+    password_env: BLACKBEAN_TEST_NO_PASSWORD
+current: prod
+`))))
+	defer viper.Set(OverrideSpec, "")
+	profile, err := GetProfile()
+	require.NoError(t, err)
+	require.Equal(t, "bulldog", profile.ClusterInfo.Password)
+
+	viper.Set(OverrideSpec, "qa")
+	_, err = GetProfile()
+	require.EqualError(t, err, `cluster profile "qa": no password resolved, set one of password, password_env, password_file, password_command`)
+}
+
+func TestPasswordHandlerNoPrompt(t *testing.T) {
+	SetTerminal(nil, 0)
+	SetNoPrompt(true)
+	defer SetNoPrompt(false)
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader([]byte(`cluster:
+  prod:
+    url: https://a.es.com
+    username: noah
+    password_file: ../testdata/password
+  staging:
+    url: https://c.es.com
+    username: noah
+    password_command: touch password_command_ran
+current: prod
+`))))
+	defer viper.Set(OverrideSpec, "")
+	profile, err := GetProfile()
+	require.NoError(t, err)
+	require.Equal(t, "bulldog", profile.ClusterInfo.Password)
+
+	viper.Set(OverrideSpec, "staging")
+	_, err = GetProfile()
+	require.EqualError(t, err, `cluster profile "staging": no password stored`)
+	_, err = os.Stat("password_command_ran")
+	require.True(t, os.IsNotExist(err))
+}
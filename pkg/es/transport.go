@@ -0,0 +1,131 @@
+package es
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ReadEndpoints are the endpoints which only read, although they take a POST for their body.
+var ReadEndpoints = []string{"_search", "_msearch", "_count", "_cluster/allocation/explain", "_pit", "_search/scroll"}
+
+// readPath matches a POST to a read endpoint of the cluster or of indices, an index
+// never starting with '_' so that /noah/_update/_search, an update of the doc with
+// id _search, is no read.
+var readPath = regexp.MustCompile(`^(/[^_/][^/]*)?/(_search|_msearch|_count|_pit)$|^/_search/scroll$|^/_cluster/allocation/explain$`)
+
+// releaseEndpoints free the search contexts of a read with a DELETE, leaving the data as it is.
+var releaseEndpoints = []string{"/_pit", "/_search/scroll"}
+
+// NewTLSConfig builds the tls config of a cluster profile from its
+// ca_cert, client_cert, client_key, server_name and insecure_skip_verify.
+func NewTLSConfig(ci *ClusterInfo) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         ci.ServerName,
+		InsecureSkipVerify: ci.InsecureSkipVerify,
+	}
+	if ci.CACert != "" {
+		pem, err := readFileExpandHome(ci.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load ca_cert")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("failed to load ca_cert %s: no PEM certificate found", ci.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+	if ci.ClientCert != "" || ci.ClientKey != "" {
+		if ci.ClientCert == "" || ci.ClientKey == "" {
+			return nil, errors.New("client_cert and client_key must be set together")
+		}
+		certPEM, err := readFileExpandHome(ci.ClientCert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client_cert")
+		}
+		keyPEM, err := readFileExpandHome(ci.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client_key")
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load client certificate pair %s, %s", ci.ClientCert, ci.ClientKey)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// NewTransport returns a http transport which talks to the cluster with the given tls config.
+func NewTransport(cfg *tls.Config) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return transport
+}
+
+// IsReadRequest reports whether req leaves the cluster as it is, GET and HEAD
+// requests, a POST to one of the ReadEndpoints or the release of a point in time or scroll.
+func IsReadRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodDelete:
+		path := strings.TrimSuffix(req.URL.Path, "/")
+		for _, e := range releaseEndpoints {
+			if path == e || strings.HasPrefix(path, e+"/") {
+				return true
+			}
+		}
+	case http.MethodPost:
+		return readPath.MatchString(strings.TrimSuffix(req.URL.Path, "/"))
+	}
+	return false
+}
+
+// ReadOnlyError is returned for a request a read only profile refuses to send.
+type ReadOnlyError struct {
+	Method string
+	Path   string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("read only profile refused %s %s, only GET, HEAD and POST to %s are sent",
+		e.Method, e.Path, strings.Join(ReadEndpoints, ", "))
+}
+
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+// NewReadOnlyTransport wraps next, refusing every request but the ones IsReadRequest allows.
+// A nil next sends with http.DefaultTransport.
+func NewReadOnlyTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &readOnlyTransport{next: next}
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !IsReadRequest(req) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &ReadOnlyError{Method: req.Method, Path: req.URL.Path}
+	}
+	return t.next.RoundTrip(req)
+}
+
+func readFileExpandHome(path string) ([]byte, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(expanded)
+}
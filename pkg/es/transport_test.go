@@ -0,0 +1,156 @@
+package es
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		info     *ClusterInfo
+		certs    int
+		rootCAs  bool
+		insecure bool
+		err      string
+	}{
+		{
+			name: "default verifies server",
+			info: &ClusterInfo{},
+		},
+		{
+			name:     "insecure skip verify",
+			info:     &ClusterInfo{InsecureSkipVerify: true},
+			insecure: true,
+		},
+		{
+			name: "ca and client cert",
+			info: &ClusterInfo{
+				CACert:     "../testdata/tls/ca.crt",
+				ClientCert: "../testdata/tls/client.crt",
+				ClientKey:  "../testdata/tls/client.key",
+				ServerName: "es.internal",
+			},
+			certs:   1,
+			rootCAs: true,
+		},
+		{
+			name: "missing ca file",
+			info: &ClusterInfo{CACert: "../testdata/tls/nosuch.crt"},
+			err:  "failed to load ca_cert: open ../testdata/tls/nosuch.crt: no such file or directory",
+		},
+		{
+			name: "not a pem file",
+			info: &ClusterInfo{CACert: "../testdata/query.yaml"},
+			err:  "failed to load ca_cert ../testdata/query.yaml: no PEM certificate found",
+		},
+		{
+			name: "client key without cert",
+			info: &ClusterInfo{ClientKey: "../testdata/tls/client.key"},
+			err:  "client_cert and client_key must be set together",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := NewTLSConfig(tc.info)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.insecure, cfg.InsecureSkipVerify)
+			require.Equal(t, tc.info.ServerName, cfg.ServerName)
+			require.Len(t, cfg.Certificates, tc.certs)
+			require.Equal(t, tc.rootCAs, cfg.RootCAs != nil)
+		})
+	}
+}
+
+func TestGetProfileTLSError(t *testing.T) {
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewReader([]byte(`cluster:
+  prod:
+    url: https://a.es.com
+    api_key: abc
+    ca_cert: ../testdata/tls/nosuch.crt
+current: prod
+`))))
+	_, err := GetProfile()
+	require.EqualError(t, err, `cluster profile "prod": failed to load ca_cert: open ../testdata/tls/nosuch.crt: no such file or directory`)
+}
+
+func TestNewTransport(t *testing.T) {
+	cfg, err := NewTLSConfig(&ClusterInfo{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	transport, ok := NewTransport(cfg).(*http.Transport)
+	require.True(t, ok)
+	require.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestIsReadRequest(t *testing.T) {
+	testCases := []struct {
+		method, url string
+		want        bool
+	}{
+		{http.MethodGet, "http://localhost:9200/_cluster/settings", true},
+		{http.MethodHead, "http://localhost:9200/noah", true},
+		{http.MethodPost, "http://localhost:9200/noah/_search", true},
+		{http.MethodPost, "http://localhost:9200/_msearch", true},
+		{http.MethodPost, "http://localhost:9200/noah/_count/", true},
+		{http.MethodPost, "http://localhost:9200/_cluster/allocation/explain", true},
+		{http.MethodPost, "http://localhost:9200/noah/_doc", false},
+		{http.MethodPost, "http://localhost:9200/noah_search/_bulk", false},
+		{http.MethodPut, "http://localhost:9200/_cluster/settings", false},
+		{http.MethodDelete, "http://localhost:9200/noah", false},
+		{http.MethodPost, "http://localhost:9200/noah/_pit?keep_alive=1m", true},
+		{http.MethodPost, "http://localhost:9200/_search/scroll", true},
+		{http.MethodDelete, "http://localhost:9200/_pit", true},
+		{http.MethodDelete, "http://localhost:9200/_search/scroll/c2Nyb2xs", true},
+		{http.MethodDelete, "http://localhost:9200/noah/_pit", false},
+		{http.MethodPost, "http://localhost:9200/noah,logs-*/_search", true},
+		{http.MethodPost, "http://localhost:9200/noah/_update/_search", false},
+		{http.MethodPost, "http://localhost:9200/noah/_create/_count", false},
+		{http.MethodPost, "http://localhost:9200/noah/_doc/_search", false},
+		{http.MethodPost, "http://localhost:9200/noah/_doc/_pit", false},
+		{http.MethodPost, "http://localhost:9200/_doc/_search", false},
+		{http.MethodPost, "http://localhost:9200/noah/_search/scroll", false},
+		{http.MethodPost, "http://localhost:9200/noah/_cluster/allocation/explain", false},
+	}
+	for _, tc := range testCases {
+		req, err := http.NewRequest(tc.method, tc.url, nil)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, IsReadRequest(req), tc.method+" "+tc.url)
+	}
+}
+
+func TestReadOnlyTransport(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	tr := NewReadOnlyTransport(nil)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/noah/_search", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	res, err := tr.RoundTrip(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	req, err = http.NewRequest(http.MethodDelete, server.URL+"/noah", nil)
+	require.NoError(t, err)
+	_, err = tr.RoundTrip(req)
+	var e *ReadOnlyError
+	require.True(t, errors.As(err, &e))
+	require.EqualError(t, err, "read only profile refused DELETE /noah, only GET, HEAD and POST to _search, _msearch, _count, _cluster/allocation/explain, _pit, _search/scroll are sent")
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
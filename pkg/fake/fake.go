@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 type Mock interface {
@@ -13,10 +14,27 @@ type Mock interface {
 
 type MockEsResponse struct {
 	ResponseString string
+	// StatusCode defaults to 200
+	StatusCode int
 }
 
 func (t *MockEsResponse) RoundTrip(*http.Request) (*http.Response, error) {
-	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(t.ResponseString))}, nil
+	code := t.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return &http.Response{StatusCode: code, Body: ioutil.NopCloser(strings.NewReader(t.ResponseString))}, nil
+}
+
+// MockEsRoutes answers by request path, other paths get a 404.
+type MockEsRoutes map[string]string
+
+func (t MockEsRoutes) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := t[req.URL.Path]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
 }
 
 type MockErrorEsResponse struct{}
@@ -24,3 +42,69 @@ type MockErrorEsResponse struct{}
 func (t *MockErrorEsResponse) RoundTrip(*http.Request) (*http.Response, error) {
 	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("send request failed"))}, errors.New("mock failed response")
 }
+
+// RecordedRequest is a request MockEsRecorder answered.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	URI    string
+	Header http.Header
+	Body   string
+}
+
+// MockEsRecorder records every request, answering the paths of Routes with their
+// body and any other path with ResponseString, {} by default.
+type MockEsRecorder struct {
+	Routes         MockEsRoutes
+	ResponseString string
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+func (t *MockEsRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := RecordedRequest{Method: req.Method, Path: req.URL.Path, URI: req.URL.RequestURI(), Header: req.Header.Clone()}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = string(body)
+	}
+	t.mu.Lock()
+	t.requests = append(t.requests, r)
+	t.mu.Unlock()
+	body, ok := t.Routes[req.URL.Path]
+	if !ok {
+		body = t.ResponseString
+	}
+	if body == "" {
+		body = `{}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+}
+
+// Requests are the requests answered so far.
+func (t *MockEsRecorder) Requests() []RecordedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedRequest{}, t.requests...)
+}
+
+// Calls are the method and path of the requests answered so far, like "GET /_cat/health".
+func (t *MockEsRecorder) Calls() []string {
+	var calls []string
+	for _, r := range t.Requests() {
+		calls = append(calls, r.Method+" "+r.Path)
+	}
+	return calls
+}
+
+// Last is the last request answered.
+func (t *MockEsRecorder) Last() RecordedRequest {
+	requests := t.Requests()
+	if len(requests) == 0 {
+		return RecordedRequest{}
+	}
+	return requests[len(requests)-1]
+}
@@ -1,6 +1,9 @@
 package fake
 
 import (
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -16,14 +19,50 @@ var _ = Describe("put settings test", func() {
 	Context("test mockTransport", func() {
 		It("test mockTransport", func() {
 			mock := MockEsResponse{
-				`{"fake":"test"}`,
+				ResponseString: `{"fake":"test"}`,
 			}
-			_, err := mock.RoundTrip(nil)
+			res, err := mock.RoundTrip(nil)
 			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(200))
+
+			notFound := MockEsResponse{
+				ResponseString: `{"fake":"test"}`,
+				StatusCode:     404,
+			}
+			res, err = notFound.RoundTrip(nil)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(404))
+
+			routes := MockEsRoutes{"/_cat/health": `[]`}
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost/_cat/health", nil)
+			res, err = routes.RoundTrip(req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(200))
+			req, _ = http.NewRequest(http.MethodGet, "http://localhost/_cat/nodes", nil)
+			res, err = routes.RoundTrip(req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(404))
 
 			errMock := MockErrorEsResponse{}
 			_, err = errMock.RoundTrip(nil)
 			Expect(err).ToNot(BeNil())
 		})
+		It("test MockEsRecorder", func() {
+			recorder := &MockEsRecorder{Routes: MockEsRoutes{"/_cat/health": `[]`}}
+			Expect(recorder.Last()).To(Equal(RecordedRequest{}))
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost/_cat/health?v", nil)
+			res, err := recorder.RoundTrip(req)
+			Expect(err).To(BeNil())
+			body, _ := ioutil.ReadAll(res.Body)
+			Expect(string(body)).To(Equal(`[]`))
+			req, _ = http.NewRequest(http.MethodPut, "http://localhost/noah", strings.NewReader(`{"settings":{}}`))
+			res, err = recorder.RoundTrip(req)
+			Expect(err).To(BeNil())
+			body, _ = ioutil.ReadAll(res.Body)
+			Expect(string(body)).To(Equal(`{}`))
+			Expect(recorder.Calls()).To(Equal([]string{"GET /_cat/health", "PUT /noah"}))
+			Expect(recorder.Requests()[0].URI).To(Equal("/_cat/health?v"))
+			Expect(recorder.Last().Body).To(Equal(`{"settings":{}}`))
+		})
 	})
 })
@@ -0,0 +1,419 @@
+// Package printer renders es responses as json, yaml, tables or plain names.
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+	"github.com/toughnoah/blackbean/pkg/util"
+	"github.com/toughnoah/blackbean/pkg/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	JSON  = "json"
+	YAML  = "yaml"
+	Table = "table"
+	Wide  = "wide"
+	Name  = "name"
+
+	// JSONPath and GoTemplate take their template after a "=", like jsonpath='{.hits.total}'.
+	JSONPath   = "jsonpath"
+	GoTemplate = "go-template"
+
+	nameHeader = "NAME"
+)
+
+// Formats lists the values accepted by -o/--output.
+var Formats = []string{JSON, YAML, Table, Wide, Name}
+
+// TemplateFormats lists the formats taking a template.
+var TemplateFormats = []string{JSONPath, GoTemplate}
+
+// Column is a table column, Path is a dotted path into an item.
+// A key containing dots, like the cat api "store.size", is matched as a whole first.
+type Column struct {
+	Header string
+	Path   string
+}
+
+// Resource tells the printer where the items of a response live and how to tabulate them.
+type Resource struct {
+	// Items is the dotted path to the list or map of items, empty means the body itself.
+	Items string
+	// NameKey is the field naming a list item, map items are named by their key.
+	// Lists without a NameKey are named by their first column.
+	NameKey string
+	// Columns are shown by table and wide, WideColumns only by wide.
+	// Without columns the keys of the first item are used.
+	Columns     []Column
+	WideColumns []Column
+}
+
+// Printer writes a response body in one of the Formats or TemplateFormats.
+type Printer struct {
+	Format   string
+	Template string
+	Resource *Resource
+}
+
+// ParseFormat splits an output flag like "jsonpath={.took}" into its format and template.
+func ParseFormat(output string) (format, tmpl string, err error) {
+	for _, f := range Formats {
+		if f == output {
+			return f, "", nil
+		}
+	}
+	for _, f := range TemplateFormats {
+		if strings.HasPrefix(output, f+"=") {
+			tmpl = strings.TrimPrefix(output, f+"=")
+			if tmpl == "" {
+				return "", "", errors.Errorf("%s output needs a template", f)
+			}
+			return f, tmpl, nil
+		}
+		if output == f {
+			return "", "", errors.Errorf("%s output needs a template, use -o %s=... or --template-file", f, f)
+		}
+	}
+	return "", "", errors.Errorf("unknown output format %q, want one of %s or %s=...", output,
+		strings.Join(Formats, "|"), strings.Join(TemplateFormats, "|"))
+}
+
+// ValidateFormat checks output is one of Formats, or one of TemplateFormats with a template.
+func ValidateFormat(output string) error {
+	_, _, err := ParseFormat(output)
+	return err
+}
+
+// New returns a Printer, r may be nil for responses without a known layout.
+func New(output string, r *Resource) (*Printer, error) {
+	format, tmpl, err := ParseFormat(output)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = &Resource{}
+	}
+	return &Printer{Format: format, Template: tmpl, Resource: r}, nil
+}
+
+// PrintResponse reads and closes the response body and prints it.
+func (p *Printer) PrintResponse(out io.Writer, res *esapi.Response) error {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return p.Print(out, body)
+}
+
+// Print prints a json body.
+func (p *Printer) Print(out io.Writer, body []byte) error {
+	switch p.Format {
+	case JSON:
+		return printJSON(out, body)
+	case YAML:
+		return printYAML(out, body)
+	case JSONPath:
+		return printJSONPath(out, p.Template, body)
+	case GoTemplate:
+		return printGoTemplate(out, p.Template, body)
+	case Name:
+		items, _, err := p.items(body)
+		if err != nil {
+			return err
+		}
+		for _, it := range items {
+			fmt.Fprintln(out, it.name)
+		}
+		return nil
+	default:
+		return p.printTable(out, body)
+	}
+}
+
+func printJSON(out io.Writer, body []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err != nil {
+		return errors.Wrap(err, "response is not json")
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+func printYAML(out io.Writer, body []byte) error {
+	y, err := yaml.JSONToYAML(body)
+	if err != nil {
+		return errors.Wrap(err, "response is not json")
+	}
+	_, err = out.Write(y)
+	return err
+}
+
+// printJSONPath executes a kubectl style jsonpath template, a bare
+// expression like ".hits.total" is wrapped in braces.
+func printJSONPath(out io.Writer, tmpl string, body []byte) error {
+	if !strings.Contains(tmpl, "{") {
+		tmpl = "{" + tmpl + "}"
+	}
+	jp := jsonpath.New("output").AllowMissingKeys(true)
+	if err := jp.Parse(tmpl); err != nil {
+		return errors.Wrap(err, "bad jsonpath template")
+	}
+	doc, err := decode(body)
+	if err != nil {
+		return err
+	}
+	return jp.Execute(out, doc)
+}
+
+func printGoTemplate(out io.Writer, tmpl string, body []byte) error {
+	t, err := template.New("output").Parse(tmpl)
+	if err != nil {
+		return errors.Wrap(err, "bad go-template")
+	}
+	doc, err := decode(body)
+	if err != nil {
+		return err
+	}
+	return t.Execute(out, doc)
+}
+
+// decode keeps integers as int64, so ids and counters print as sent.
+func decode(body []byte) (interface{}, error) {
+	var doc interface{}
+	if err := util.Unmarshal(body, &doc); err != nil {
+		return nil, errors.Wrap(err, "response is not json")
+	}
+	return doc, nil
+}
+
+type item struct {
+	name  string
+	value interface{}
+	// raw keeps the item as sent, to get its keys in order
+	raw json.RawMessage
+}
+
+// items splits a body into named items. named reports whether the names
+// need a column of their own: map items are named by key, list items only
+// when their NameKey is not a column already.
+func (p *Printer) items(body []byte) (items []item, named bool, err error) {
+	doc, err := decode(body)
+	if err != nil {
+		return nil, false, err
+	}
+	raw := json.RawMessage(body)
+	if p.Resource.Items != "" {
+		var ok bool
+		if doc, ok = Lookup(doc, p.Resource.Items); !ok {
+			return nil, false, errors.Errorf("no %q in response", p.Resource.Items)
+		}
+		raw = lookupRaw(body, p.Resource.Items)
+	}
+	switch v := doc.(type) {
+	case []interface{}:
+		var raws []json.RawMessage
+		_ = json.Unmarshal(raw, &raws)
+		for i, e := range v {
+			it := item{value: e}
+			if i < len(raws) {
+				it.raw = raws[i]
+			}
+			items = append(items, it)
+		}
+		for i := range items {
+			items[i].name = p.listItemName(items[i])
+		}
+		named = p.Resource.NameKey != "" && !p.hasColumn(p.Resource.NameKey)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var raws map[string]json.RawMessage
+		_ = json.Unmarshal(raw, &raws)
+		for _, k := range keys {
+			items = append(items, item{name: k, value: v[k], raw: raws[k]})
+		}
+		named = true
+	default:
+		items = append(items, item{name: Format(v), value: v})
+	}
+	return items, named, nil
+}
+
+func (p *Printer) hasColumn(path string) bool {
+	for _, c := range p.Resource.Columns {
+		if c.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Printer) listItemName(it item) string {
+	if p.Resource.NameKey != "" {
+		v, _ := Lookup(it.value, p.Resource.NameKey)
+		return Format(v)
+	}
+	if keys := Keys(it.raw); len(keys) > 0 {
+		v, _ := Lookup(it.value, keys[0])
+		return Format(v)
+	}
+	return Format(it.value)
+}
+
+func (p *Printer) columns(items []item) []Column {
+	columns := p.Resource.Columns
+	if len(columns) == 0 && len(items) > 0 {
+		for _, k := range Keys(items[0].raw) {
+			columns = append(columns, Column{Header: strings.ToUpper(k), Path: k})
+		}
+	}
+	if p.Format == Wide {
+		columns = append(columns, p.Resource.WideColumns...)
+	}
+	return columns
+}
+
+func (p *Printer) printTable(out io.Writer, body []byte) error {
+	items, named, err := p.items(body)
+	if err != nil {
+		return err
+	}
+	columns := p.columns(items)
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	var headers []string
+	if named {
+		headers = append(headers, nameHeader)
+	}
+	for _, c := range columns {
+		headers = append(headers, c.Header)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, it := range items {
+		var cells []string
+		if named {
+			cells = append(cells, it.name)
+		}
+		for _, c := range columns {
+			v, _ := Lookup(it.value, c.Path)
+			cells = append(cells, Format(v))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// Lookup walks a dotted path through decoded json.
+func Lookup(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if found, ok := m[path]; ok {
+		return found, true
+	}
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if next, ok := m[strings.Join(parts[:i], ".")]; ok {
+			return Lookup(next, strings.Join(parts[i:], "."))
+		}
+	}
+	return nil, false
+}
+
+func lookupRaw(body []byte, path string) json.RawMessage {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil
+	}
+	if found, ok := m[path]; ok {
+		return found
+	}
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if next, ok := m[strings.Join(parts[:i], ".")]; ok {
+			return lookupRaw(next, strings.Join(parts[i:], "."))
+		}
+	}
+	return nil
+}
+
+// Keys returns the keys of a json object in the order they were sent.
+func Keys(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	var keys []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		keys = append(keys, t.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return keys
+		}
+	}
+	return keys
+}
+
+// Format renders a decoded json value as a table cell.
+// Lists are joined by commas, objects are shown by their keys.
+func Format(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []interface{}:
+		cells := make([]string, 0, len(t))
+		for _, e := range t {
+			cells = append(cells, formatNested(e))
+		}
+		return strings.Join(cells, ",")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return strings.Join(keys, ",")
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func formatNested(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return Format(v)
+}
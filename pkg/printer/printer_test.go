@@ -0,0 +1,168 @@
+package printer
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	catBody      = `[{"index":"noah-1","store.size":"10gb"},{"index":"noah-2","store.size":"1gb"}]`
+	snapshotBody = `{"snapshots":[{"snapshot":"snap-1","state":"SUCCESS","shards":{"total":5,"failed":0}},{"snapshot":"snap-2","state":"FAILED","shards":{"total":5,"failed":2}}]}`
+	userBody     = `{"noah":{"username":"noah","roles":["admin","dev"],"enabled":true},"blackbean":{"username":"blackbean","roles":[],"enabled":false}}`
+)
+
+var (
+	snapshotLayout = &Resource{
+		Items:       "snapshots",
+		NameKey:     "snapshot",
+		Columns:     []Column{{Header: "STATE", Path: "state"}},
+		WideColumns: []Column{{Header: "FAILED", Path: "shards.failed"}},
+	}
+	userLayout = &Resource{
+		Columns: []Column{{Header: "ROLES", Path: "roles"}, {Header: "ENABLED", Path: "enabled"}},
+	}
+)
+
+func TestPrint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		layout *Resource
+		body   string
+		want   string
+	}{
+		{
+			name:   "cat table keeps column order",
+			format: Table,
+			body:   catBody,
+			want:   "INDEX    STORE.SIZE\nnoah-1   10gb\nnoah-2   1gb\n",
+		},
+		{
+			name:   "cat names use the first column",
+			format: Name,
+			body:   catBody,
+			want:   "noah-1\nnoah-2\n",
+		},
+		{
+			name:   "list items with a name key",
+			format: Table,
+			layout: snapshotLayout,
+			body:   snapshotBody,
+			want:   "NAME     STATE\nsnap-1   SUCCESS\nsnap-2   FAILED\n",
+		},
+		{
+			name:   "wide adds nested columns",
+			format: Wide,
+			layout: snapshotLayout,
+			body:   snapshotBody,
+			want:   "NAME     STATE     FAILED\nsnap-1   SUCCESS   0\nsnap-2   FAILED    2\n",
+		},
+		{
+			name:   "map items are named by key",
+			format: Table,
+			layout: userLayout,
+			body:   userBody,
+			want:   "NAME        ROLES       ENABLED\nblackbean               false\nnoah        admin,dev   true\n",
+		},
+		{
+			name:   "names of map items",
+			format: Name,
+			layout: userLayout,
+			body:   userBody,
+			want:   "blackbean\nnoah\n",
+		},
+		{
+			name:   "json is indented",
+			format: JSON,
+			body:   `{"a":{"b":12345678901234567890}}`,
+			want:   "{\n  \"a\": {\n    \"b\": 12345678901234567890\n  }\n}\n",
+		},
+		{
+			name:   "jsonpath keeps number precision",
+			format: `jsonpath={range .hits.hits[*]}{._id}={._source.n}{"\n"}{end}`,
+			body:   `{"hits":{"hits":[{"_id":"a","_source":{"n":9007199254740993}},{"_id":"b","_source":{"n":1.5}}]}}`,
+			want:   "a=9007199254740993\nb=1.5\n",
+		},
+		{
+			name:   "bare jsonpath expression",
+			format: "jsonpath=.hits.total.value",
+			body:   `{"hits":{"total":{"value":42}}}`,
+			want:   "42",
+		},
+		{
+			name:   "jsonpath filter compares strings and numbers",
+			format: `jsonpath={.hits.hits[?(@._source.n>=2)]._id} {.hits.hits[?(@._id=="a")]._source.n}`,
+			body:   `{"hits":{"hits":[{"_id":"a","_source":{"n":1}},{"_id":"b","_source":{"n":2}},{"_id":"c","_source":{"n":3}}]}}`,
+			want:   "b c 1",
+		},
+		{
+			name:   "jsonpath dotted cat columns",
+			format: `jsonpath={range [*]}{.index} {.store\.size}{"\n"}{end}`,
+			body:   catBody,
+			want:   "noah-1 10gb\nnoah-2 1gb\n",
+		},
+		{
+			name:   "jsonpath missing keys print nothing",
+			format: `jsonpath={.hits.total.value}{.aggregations.no.buckets}`,
+			body:   `{"hits":{"total":{"value":0}}}`,
+			want:   "0",
+		},
+		{
+			name:   "go-template",
+			format: `go-template={{range .}}{{.index}} {{index . "store.size"}}{{"\n"}}{{end}}`,
+			body:   catBody,
+			want:   "noah-1 10gb\nnoah-2 1gb\n",
+		},
+		{
+			name:   "yaml",
+			format: YAML,
+			body:   `{"a":["b","c"]}`,
+			want:   "a:\n- b\n- c\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.format, tc.layout)
+			require.NoError(t, err)
+			out := new(bytes.Buffer)
+			require.NoError(t, p.Print(out, []byte(tc.body)))
+			require.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestPrintErrors(t *testing.T) {
+	_, err := New("xml", nil)
+	require.EqualError(t, err, `unknown output format "xml", want one of json|yaml|table|wide|name or jsonpath|go-template=...`)
+	_, err = New("jsonpath", nil)
+	require.EqualError(t, err, "jsonpath output needs a template, use -o jsonpath=... or --template-file")
+	_, err = New("go-template=", nil)
+	require.EqualError(t, err, "go-template output needs a template")
+
+	jp, err := New("jsonpath={.a", nil)
+	require.NoError(t, err)
+	require.Error(t, jp.Print(new(bytes.Buffer), []byte(`{"a":1}`)))
+
+	p, err := New(Table, snapshotLayout)
+	require.NoError(t, err)
+	require.EqualError(t, p.Print(new(bytes.Buffer), []byte(`{"other":[]}`)), `no "snapshots" in response`)
+	require.Error(t, p.Print(new(bytes.Buffer), []byte(`not json`)))
+}
+
+func TestLookup(t *testing.T) {
+	doc := map[string]interface{}{
+		"store.size": "1gb",
+		"settings": map[string]interface{}{
+			"index": map[string]interface{}{"number_of_shards": "3"},
+		},
+	}
+	v, ok := Lookup(doc, "store.size")
+	require.True(t, ok)
+	require.Equal(t, "1gb", v)
+	v, ok = Lookup(doc, "settings.index.number_of_shards")
+	require.True(t, ok)
+	require.Equal(t, "3", v)
+	_, ok = Lookup(doc, "settings.missing")
+	require.False(t, ok)
+}